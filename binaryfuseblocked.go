@@ -0,0 +1,331 @@
+package xorfilter
+
+import (
+	"errors"
+	"slices"
+	"unsafe"
+)
+
+// cacheLineSize is the size, in bytes, of the blocks of a BinaryFuseBlocked.
+const cacheLineSize = 64
+
+// blockedSeeds is the number of block seeds tried for a given set of keys
+// before keys are bumped out of the block. More seeds bump fewer keys but
+// slow down construction: 64 seeds instead of 16 bump about a third fewer
+// keys and take twice as long.
+const blockedSeeds = 16
+
+// blockedLoad is the average number of keys per block, as a fraction of the
+// fingerprint slots of a block. Blocks are independent, so the number of keys
+// in a block varies; the keys that do not fit are bumped to the overflow
+// filter. Measured with one million random keys and 8-bit fingerprints, a
+// load of 0.7 bumps 1% of the keys and uses 11.9 bits per key, 0.8 bumps 4%
+// and uses 10.7 bits per key, and 0.9 bumps 10% and uses 10.0 bits per key.
+const blockedLoad = 0.8
+
+// BinaryFuseBlocked is a variant of the binary fuse filter whose queries
+// touch a single cache line. The fingerprints are split into 64-byte blocks,
+// aligned in memory to cache lines; a key is hashed to one block and its
+// three fingerprints are in that block.
+//
+// Within a block, fingerprints form an independent xor filter over the keys
+// of the block, with a seed stored in the block itself, so that a block that
+// cannot be built is retried locally with another seed. Keys that still do
+// not fit are bumped, by decreasing rank, to a regular BinaryFuse[T] overflow
+// filter; the block records the rank threshold so that only queries for
+// bumped ranks (a few percent) reach the overflow filter.
+//
+// The filter uses more memory than BinaryFuse[T] for the same false positive
+// probability: about 10.7 bits per key with 8-bit fingerprints instead of 9,
+// and 22 bits per key with 16-bit fingerprints instead of 18. About 4% (8-bit)
+// to 6% (16-bit) of the queries also read the overflow filter.
+//
+// Reading one cache line instead of three reduces memory traffic, which helps
+// when many queries compete for memory bandwidth. It does not necessarily
+// make a single query faster: the three loads of BinaryFuse[T] are
+// independent and proceed in parallel, while here the block header must be
+// read before the positions are known. On a single core with 50 million keys,
+// BenchmarkBinaryFuseBlockedContains50000000 measured about 105 ns per query
+// against 80-90 ns for BenchmarkBinaryFuseNContains50000000.
+type BinaryFuseBlocked[T Unsigned] struct {
+	Seed       uint64
+	BlockCount uint32
+
+	// Fingerprints holds BlockCount blocks of 64 bytes. The first bytes of
+	// each block hold its seed and rank threshold.
+	Fingerprints []T
+	// Overflow holds the bumped keys; it is nil if there are none.
+	Overflow *BinaryFuse[T]
+}
+
+// blockSlots returns the number of fingerprints per block, including the
+// metadata slots.
+func blockSlots[T Unsigned]() uint32 {
+	return cacheLineSize / uint32(unsafe.Sizeof(T(0)))
+}
+
+// blockMetaSlots returns the number of slots of a block holding its seed and
+// rank threshold (one byte each).
+func blockMetaSlots[T Unsigned]() uint32 {
+	if unsafe.Sizeof(T(0)) == 1 {
+		return 2
+	}
+	return 1
+}
+
+// NewBinaryFuseBlocked creates a blocked binary fuse filter with provided
+// keys. The keys slice is not modified.
+func NewBinaryFuseBlocked[T Unsigned](keys []uint64) (*BinaryFuseBlocked[T], error) {
+	var b BinaryFuseBuilder
+	filter, err := BuildBinaryFuseBlocked[T](&b, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// BuildBinaryFuseBlocked creates a blocked binary fuse filter with provided
+// keys, using the BinaryFuseBuilder for the overflow filter. Unlike
+// BuildBinaryFuse, the resulting filter does not share memory with the
+// builder. The keys slice is not modified.
+func BuildBinaryFuseBlocked[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (BinaryFuseBlocked[T], error) {
+	slots := blockSlots[T]()
+	meta := blockMetaSlots[T]()
+	m := slots - meta
+	var filter BinaryFuseBlocked[T]
+	rngcounter := uint64(1)
+	filter.Seed = splitmix64(&rngcounter)
+	blockCount := uint64(float64(len(keys))/(blockedLoad*float64(m))) + 1
+	if blockCount*uint64(slots) > 1<<32-1 {
+		return BinaryFuseBlocked[T]{}, errors.New("too many keys")
+	}
+	filter.BlockCount = uint32(blockCount)
+	filter.Fingerprints = alignedFingerprints[T](filter.BlockCount * slots)
+
+	// Sort the hashes by block, keeping the key of each hash for the overflow
+	// filter.
+	type keyHash struct {
+		hash, key uint64
+	}
+	entries := make([]keyHash, len(keys))
+	starts := make([]uint32, filter.BlockCount+1)
+	for _, key := range keys {
+		starts[filter.block(mixsplit(key, filter.Seed))+1]++
+	}
+	for i := uint32(1); i <= filter.BlockCount; i++ {
+		starts[i] += starts[i-1]
+	}
+	next := slices.Clone(starts[:filter.BlockCount])
+	for _, key := range keys {
+		hash := mixsplit(key, filter.Seed)
+		block := filter.block(hash)
+		entries[next[block]] = keyHash{hash: hash, key: key}
+		next[block]++
+	}
+
+	var bumped []uint64
+	var hashes []uint64
+	var p blockPeeler
+	for block := uint32(0); block < filter.BlockCount; block++ {
+		blockEntries := entries[starts[block]:starts[block+1]]
+		// Order by decreasing rank so that the keys to bump come first.
+		// Duplicated keys end up next to each other and are skipped.
+		slices.SortFunc(blockEntries, func(a, b keyHash) int {
+			if ra, rb := blockRank(a.hash), blockRank(b.hash); ra != rb {
+				return int(rb) - int(ra)
+			}
+			if a.hash < b.hash {
+				return -1
+			} else if a.hash > b.hash {
+				return 1
+			}
+			return 0
+		})
+		hashes = hashes[:0]
+		for i, e := range blockEntries {
+			if i == 0 || e.hash != blockEntries[i-1].hash {
+				hashes = append(hashes, e.hash)
+			}
+		}
+
+		// Lower the threshold, one rank at a time, until the remaining keys
+		// can be peeled with one of the block seeds. An empty block always
+		// succeeds.
+		threshold := uint8(blockRanks)
+		first := 0
+		for {
+			if seed, ok := p.peel(hashes[first:], m, blockedSeeds); ok {
+				fps := filter.Fingerprints[block*slots : (block+1)*slots]
+				setBlockMeta(fps, seed, threshold)
+				assignBlock(&p, fps[meta:], m, seed)
+				break
+			}
+			threshold = blockRank(hashes[first])
+			for first < len(hashes) && blockRank(hashes[first]) >= threshold {
+				first++
+			}
+		}
+		for _, e := range blockEntries {
+			if blockRank(e.hash) >= threshold {
+				bumped = append(bumped, e.key)
+			}
+		}
+	}
+
+	if len(bumped) > 0 {
+		overflow, err := buildOwned[T](b, bumped)
+		if err != nil {
+			return BinaryFuseBlocked[T]{}, err
+		}
+		filter.Overflow = &overflow
+	}
+	return filter, nil
+}
+
+// alignedFingerprints returns n zeroed fingerprints whose first element is
+// aligned to a cache line.
+func alignedFingerprints[T Unsigned](n uint32) []T {
+	size := uint32(unsafe.Sizeof(T(0)))
+	buf := make([]T, n+cacheLineSize/size)
+	offset := uint32(uintptr(unsafe.Pointer(unsafe.SliceData(buf))) % cacheLineSize)
+	if offset != 0 {
+		offset = (cacheLineSize - offset) / size
+	}
+	return buf[offset : offset+n : offset+n]
+}
+
+func (filter *BinaryFuseBlocked[T]) block(hash uint64) uint32 {
+	return reduce(uint32(hash>>32), filter.BlockCount)
+}
+
+// blockRanks is the number of distinct ranks. A block stores the keys whose
+// rank is below its threshold, between 0 and blockRanks.
+const blockRanks = 128
+
+// blockRank returns the rank of a hash, used to decide which keys are bumped
+// out of their block. It uses bits that the block index and the fingerprint
+// do not depend on.
+func blockRank(hash uint64) uint8 {
+	return uint8(hash>>16) % blockRanks
+}
+
+func setBlockMeta[T Unsigned](fps []T, seed, threshold uint8) {
+	if unsafe.Sizeof(T(0)) == 1 {
+		fps[0] = T(seed)
+		fps[1] = T(threshold)
+	} else {
+		fps[0] = T(uint32(seed) | uint32(threshold)<<8)
+	}
+}
+
+func blockMeta[T Unsigned](fps []T) (seed, threshold uint8) {
+	if unsafe.Sizeof(T(0)) == 1 {
+		return uint8(fps[0]), uint8(fps[1])
+	}
+	return uint8(fps[0]), uint8(uint32(fps[0]) >> 8)
+}
+
+// blockPositions returns the three positions, among the m fingerprint slots
+// of a block, of the given hash for the given block seed. As in Xor8, the
+// slots are split into three parts and each position is in its own part. A
+// single multiplication is enough to give each seed a different graph, and
+// keeps the dependent computation after reading the block header short.
+func blockPositions(hash uint64, seed uint8, m uint32) (uint32, uint32, uint32) {
+	h := (hash ^ uint64(seed)*0x9E3779B97F4A7C15) * 0xff51afd7ed558ccd
+	part := m / 3
+	h0 := reduce(uint32(h>>32), part)
+	h1 := part + reduce(uint32(h>>11), part)
+	h2 := 2*part + reduce(uint32(rotl64(h, 42)), m-2*part)
+	return h0, h1, h2
+}
+
+// blockPeeler peels the keys of a single block.
+type blockPeeler struct {
+	count [cacheLineSize]uint8
+	xors  [cacheLineSize]uint64
+	queue [cacheLineSize]uint32
+	// stack holds the hashes in peeling order and the slot each one is
+	// assigned to.
+	stack []keyindex
+}
+
+// peel tries up to numSeeds block seeds and returns the first one with which
+// all the hashes can be peeled. On success, p.stack holds the peeling order.
+func (p *blockPeeler) peel(hashes []uint64, m uint32, numSeeds int) (uint8, bool) {
+	if uint32(len(hashes)) > m {
+		return 0, false
+	}
+	for seed := 0; seed < numSeeds; seed++ {
+		clear(p.count[:m])
+		clear(p.xors[:m])
+		for _, hash := range hashes {
+			h0, h1, h2 := blockPositions(hash, uint8(seed), m)
+			p.count[h0]++
+			p.xors[h0] ^= hash
+			p.count[h1]++
+			p.xors[h1] ^= hash
+			p.count[h2]++
+			p.xors[h2] ^= hash
+		}
+		qsize := 0
+		for i := uint32(0); i < m; i++ {
+			if p.count[i] == 1 {
+				p.queue[qsize] = i
+				qsize++
+			}
+		}
+		p.stack = p.stack[:0]
+		for qsize > 0 {
+			qsize--
+			index := p.queue[qsize]
+			if p.count[index] != 1 {
+				continue
+			}
+			hash := p.xors[index]
+			p.stack = append(p.stack, keyindex{hash: hash, index: index})
+			h0, h1, h2 := blockPositions(hash, uint8(seed), m)
+			for _, h := range [3]uint32{h0, h1, h2} {
+				p.count[h]--
+				p.xors[h] ^= hash
+				if p.count[h] == 1 {
+					p.queue[qsize] = h
+					qsize++
+				}
+			}
+		}
+		if len(p.stack) == len(hashes) {
+			return uint8(seed), true
+		}
+	}
+	return 0, false
+}
+
+// assignBlock fills the fingerprint slots of a block from the peeling order
+// found by p.
+func assignBlock[T Unsigned](p *blockPeeler, fps []T, m uint32, seed uint8) {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		ki := p.stack[i]
+		h0, h1, h2 := blockPositions(ki.hash, seed, m)
+		// The slot being assigned is still zero, so xoring all three slots is
+		// the same as xoring the other two.
+		fps[ki.index] = T(fingerprint(ki.hash)) ^ fps[h0] ^ fps[h1] ^ fps[h2]
+	}
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability of about 2^-(8*sizeof(T)). Most queries read a single cache
+// line; those for a bumped rank also query the overflow filter.
+func (filter *BinaryFuseBlocked[T]) Contains(key uint64) bool {
+	hash := mixsplit(key, filter.Seed)
+	slots := blockSlots[T]()
+	start := filter.block(hash) * slots
+	fps := filter.Fingerprints[start : start+slots : start+slots]
+	seed, threshold := blockMeta(fps)
+	if blockRank(hash) >= threshold {
+		return filter.Overflow != nil && filter.Overflow.Contains(key)
+	}
+	meta := blockMetaSlots[T]()
+	h0, h1, h2 := blockPositions(hash, seed, slots-meta)
+	fps = fps[meta:]
+	return T(fingerprint(hash))^fps[h0]^fps[h1]^fps[h2] == 0
+}
@@ -0,0 +1,103 @@
+package xorfilter
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryFuseBlocked(t *testing.T) {
+	keys := make([]uint64, 200_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	checkBlocked[uint8](t, keys)
+	checkBlocked[uint16](t, keys)
+	checkBlocked[uint32](t, keys)
+}
+
+func checkBlocked[T Unsigned](t *testing.T, keys []uint64) {
+	t.Helper()
+	original := slices.Clone(keys)
+	filter, err := NewBinaryFuseBlocked[T](keys)
+	require.NoError(t, err)
+	require.Equal(t, original, keys)
+	for _, v := range keys {
+		require.True(t, filter.Contains(v))
+	}
+
+	falsesize := 1_000_000
+	matches := 0
+	for i := 0; i < falsesize; i++ {
+		if filter.Contains(rand.Uint64()) {
+			matches++
+		}
+	}
+	fpp := float64(matches) / float64(falsesize)
+	expected := math.Pow(2, -8*float64(unsafe.Sizeof(T(0))))
+	// The number of matches is binomial; allow 5 standard deviations.
+	sigma := math.Sqrt(expected * (1 - expected) / float64(falsesize))
+	overflow := 0
+	if filter.Overflow != nil {
+		overflow = len(filter.Overflow.Fingerprints)
+	}
+	bpv := float64(len(filter.Fingerprints)+overflow) * 8 * float64(unsafe.Sizeof(T(0))) / float64(len(keys))
+	t.Logf("%d-bit: bits per entry %.2f false positive rate %.6f", 8*unsafe.Sizeof(T(0)), bpv, fpp)
+	assert.Less(t, fpp, expected+5*sigma)
+}
+
+// TestBinaryFuseBlockedLocality verifies that the fingerprints read by a query
+// that does not reach the overflow filter lie in a single cache line.
+func TestBinaryFuseBlockedLocality(t *testing.T) {
+	keys := make([]uint64, 10_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := NewBinaryFuseBlocked[uint16](keys)
+	require.NoError(t, err)
+	require.Zero(t, uintptr(unsafe.Pointer(&filter.Fingerprints[0]))%cacheLineSize)
+
+	slots := blockSlots[uint16]()
+	meta := blockMetaSlots[uint16]()
+	for i := 0; i < 10_000; i++ {
+		hash := mixsplit(rand.Uint64(), filter.Seed)
+		start := filter.block(hash) * slots
+		seed, _ := blockMeta(filter.Fingerprints[start:])
+		h0, h1, h2 := blockPositions(hash, seed, slots-meta)
+		line := uintptr(unsafe.Pointer(&filter.Fingerprints[start])) / cacheLineSize
+		for _, h := range []uint32{h0, h1, h2} {
+			require.Equal(t, line, uintptr(unsafe.Pointer(&filter.Fingerprints[start+meta+h]))/cacheLineSize)
+		}
+	}
+}
+
+// TestBinaryFuseBlockedSizes verifies that construction succeeds across sizes,
+// including with duplicated keys, and that only a few keys are bumped.
+func TestBinaryFuseBlockedSizes(t *testing.T) {
+	var b BinaryFuseBuilder
+	for _, n := range []int{0, 1, 2, 10, 100, 1000, 10_000, 100_000, 1_000_000} {
+		for trial := 0; trial < 5; trial++ {
+			keys := make([]uint64, n)
+			for i := range keys {
+				keys[i] = rand.Uint64()
+			}
+			if n > 1 {
+				keys[n-1] = keys[0]
+			}
+			filter, err := BuildBinaryFuseBlocked[uint8](&b, keys)
+			require.NoError(t, err, "n=%d", n)
+			for _, v := range keys {
+				require.True(t, filter.Contains(v))
+			}
+			if n >= 100_000 && filter.Overflow != nil {
+				bumped := float64(len(filter.Overflow.Fingerprints)) / 1.125 / float64(n)
+				require.Less(t, bumped, 0.06, "n=%d", n)
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"math"
 	"math/bits"
+	"slices"
 	"unsafe"
 )
 
@@ -24,7 +25,7 @@ type BinaryFuse[T Unsigned] struct {
 // NewBinaryFuse creates a binary fuse filter with provided keys. For best
 // results, the caller should avoid having too many duplicated keys.
 //
-// The function can mutate the given keys slice to remove duplicates.
+// The keys slice is not modified: duplicates are removed in a copy.
 //
 // The function may return an error if the set is empty.
 func NewBinaryFuse[T Unsigned](keys []uint64) (*BinaryFuse[T], error) {
@@ -50,6 +51,10 @@ type BinaryFuseBuilder struct {
 	reverseH     []uint8
 	startPos     []uint32
 	fingerprints []uint32
+	packed       []uint64
+	// keys holds a deduplicated copy of the keys when the build finds
+	// duplicates, so that the caller's slice is never modified.
+	keys []uint64
 }
 
 // MakeBinaryFuseBuilder creates a BinaryFuseBuilder with enough preallocated
@@ -85,7 +90,8 @@ func MakeBinaryFuseBuilder[T Unsigned](initialSize int) BinaryFuseBuilder {
 // The Fingerprints slice in the resulting filter is owned by the builder; it
 // is only valid until the BinaryFuseBuilder is used again.
 //
-// The function can mutate the given keys slice to remove duplicates.
+// The keys slice is not modified: duplicates are removed in a copy owned by
+// the builder.
 //
 // The function may return an error if the set is empty.
 func BuildBinaryFuse[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (BinaryFuse[T], error) {
@@ -94,12 +100,74 @@ func BuildBinaryFuse[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (BinaryFus
 }
 
 func buildBinaryFuse[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (_ BinaryFuse[T], iterations int, _ error) {
-	size := uint32(len(keys))
+	return buildBinaryFuseSeeded[T](b, keys, nil)
+}
+
+// buildOwned is BuildBinaryFuse, except that the fingerprints of the filter
+// are copied out of the builder, so that the filter outlives its next use.
+func buildOwned[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (BinaryFuse[T], error) {
+	return buildOwnedSeeded[T](b, keys, nil)
+}
+
+// buildOwnedSeeded is buildOwned, trying the given seed first if it is not
+// nil.
+func buildOwnedSeeded[T Unsigned](b *BinaryFuseBuilder, keys []uint64, seed *uint64) (BinaryFuse[T], error) {
+	filter, _, err := buildBinaryFuseSeeded[T](b, keys, seed)
+	if err != nil {
+		return BinaryFuse[T]{}, err
+	}
+	filter.Fingerprints = slices.Clone(filter.Fingerprints)
+	return filter, nil
+}
+
+// buildBinaryFuseSeeded is buildBinaryFuse, trying the given seed first if
+// it is not nil.
+func buildBinaryFuseSeeded[T Unsigned](b *BinaryFuseBuilder, keys []uint64, seed *uint64) (_ BinaryFuse[T], iterations int, _ error) {
 	var filter BinaryFuse[T]
-	filter.initializeParameters(b, size)
+	size, capacity, iterations, err := filter.peel(b, keys, seed)
+	if err != nil {
+		return BinaryFuse[T]{}, iterations, err
+	}
+	filter.allocateFingerprints(b, capacity)
+	if size == 0 {
+		return filter, iterations, nil
+	}
+
+	reverseOrder := b.reverseOrder
+	reverseH := b.reverseH
+	var h012 [5]uint32
+	for i := int(size - 1); i >= 0; i-- {
+		// the hash of the key we insert next
+		hash := reverseOrder[i]
+		xor2 := T(fingerprint(hash))
+		index1, index2, index3 := filter.getHashFromHash(hash)
+		found := reverseH[i]
+		h012[0] = index1
+		h012[1] = index2
+		h012[2] = index3
+		h012[3] = h012[0]
+		h012[4] = h012[1]
+		filter.Fingerprints[h012[found]] = xor2 ^ filter.Fingerprints[h012[found+1]] ^ filter.Fingerprints[h012[found+2]]
+	}
+
+	return filter, iterations, nil
+}
+
+// peel computes the filter parameters and searches for a seed with which all
+// the keys can be peeled. On success, the first size entries of b.reverseOrder
+// and b.reverseH describe the peeling order, and capacity is the number of
+// fingerprints the filter needs. The Fingerprints slice is left untouched.
+//
+// If seed is not nil, it is tried first, before the usual sequence of seeds.
+func (filter *BinaryFuse[T]) peel(b *BinaryFuseBuilder, keys []uint64, seed *uint64) (size, capacity uint32, iterations int, _ error) {
+	size = uint32(len(keys))
+	capacity = filter.setParameters(size)
 	rngcounter := uint64(1)
-	filter.Seed = splitmix64(&rngcounter)
-	capacity := uint32(len(filter.Fingerprints))
+	if seed != nil {
+		filter.Seed = *seed
+	} else {
+		filter.Seed = splitmix64(&rngcounter)
+	}
 
 	alone := reuseBuffer(&b.alone, capacity)
 	// the lowest 2 bits are the h index (0, 1, or 2)
@@ -117,7 +185,7 @@ func buildBinaryFuse[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (_ BinaryF
 		if iterations > MaxIterations {
 			// The probability of this happening is lower than the cosmic-ray
 			// probability (i.e., a cosmic ray corrupts your system).
-			return BinaryFuse[T]{}, iterations, errors.New("too many iterations")
+			return 0, 0, iterations, errors.New("too many iterations")
 		}
 		if size > 4 && size < 1_000_000 {
 			// The segment length is calculated using an empirical formula. For some
@@ -304,9 +372,10 @@ func buildBinaryFuse[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (_ BinaryF
 		} else if duplicates > 0 {
 			// Duplicates were found, but we did not
 			// manage to remove them all. We may simply sort the key to
-			// solve the issue. This will run in time O(n log n) and it
-			// mutates the input.
-			keys = pruneDuplicates(keys)
+			// solve the issue. This will run in time O(n log n). The keys
+			// are copied first, so that the input is not modified.
+			b.keys = pruneDuplicates(append(b.keys[:0], keys...))
+			keys = b.keys
 		}
 		for i := uint32(0); i < size; i++ {
 			reverseOrder[i] = 0
@@ -317,29 +386,16 @@ func buildBinaryFuse[T Unsigned](b *BinaryFuseBuilder, keys []uint64) (_ BinaryF
 		}
 		filter.Seed = splitmix64(&rngcounter)
 	}
-	if size == 0 {
-		return filter, iterations, nil
-	}
-
-	var h012 [5]uint32
-	for i := int(size - 1); i >= 0; i-- {
-		// the hash of the key we insert next
-		hash := reverseOrder[i]
-		xor2 := T(fingerprint(hash))
-		index1, index2, index3 := filter.getHashFromHash(hash)
-		found := reverseH[i]
-		h012[0] = index1
-		h012[1] = index2
-		h012[2] = index3
-		h012[3] = h012[0]
-		h012[4] = h012[1]
-		filter.Fingerprints[h012[found]] = xor2 ^ filter.Fingerprints[h012[found+1]] ^ filter.Fingerprints[h012[found+2]]
-	}
-
-	return filter, iterations, nil
+	return size, capacity, iterations, nil
 }
 
 func (filter *BinaryFuse[T]) initializeParameters(b *BinaryFuseBuilder, size uint32) {
+	filter.allocateFingerprints(b, filter.setParameters(size))
+}
+
+// setParameters computes the segment layout for the given number of keys and
+// returns the number of fingerprints it requires.
+func (filter *BinaryFuse[T]) setParameters(size uint32) uint32 {
 	arity := uint32(3)
 	filter.SegmentLength = calculateSegmentLength(arity, size)
 	if filter.SegmentLength > 262144 {
@@ -357,9 +413,59 @@ func (filter *BinaryFuse[T]) initializeParameters(b *BinaryFuseBuilder, size uin
 	}
 	filter.SegmentCount = totalSegmentCount - (arity - 1)
 	filter.SegmentCountLength = filter.SegmentCount * filter.SegmentLength
+	return totalSegmentCount * filter.SegmentLength
+}
+
+// checkLayout verifies that the segment parameters of a loaded filter are
+// consistent, so that queries stay within numFingerprints fingerprints.
+func checkLayout(segmentLength, segmentLengthMask, segmentCount, segmentCountLength uint32, numFingerprints uint64) error {
+	if segmentLength == 0 || segmentLength&(segmentLength-1) != 0 {
+		return errors.New("segment length is not a power of two")
+	}
+	if segmentLengthMask != segmentLength-1 {
+		return errors.New("segment length mask does not match the segment length")
+	}
+	if uint64(segmentCountLength) != uint64(segmentCount)*uint64(segmentLength) {
+		return errors.New("segment count length does not match the segment count")
+	}
+	if numFingerprints != (uint64(segmentCount)+2)*uint64(segmentLength) {
+		return errors.New("fingerprint length does not match the filter parameters")
+	}
+	return nil
+}
+
+// Validate checks that the filter parameters are consistent with the length of
+// the Fingerprints slice, so that Contains cannot index out of range. It is
+// meant for filters obtained from untrusted sources.
+func (filter *BinaryFuse[T]) Validate() error {
+	return checkLayout(filter.SegmentLength, filter.SegmentLengthMask, filter.SegmentCount, filter.SegmentCountLength, uint64(len(filter.Fingerprints)))
+}
+
+// MaxKeys returns the largest number of keys for which BuildBinaryFuse
+// allocates as many fingerprints as the filter has. The number of keys is not
+// stored in the filter; MaxKeys gives an upper bound, from which the memory
+// usage per key of a loaded filter can be estimated.
+func (filter *BinaryFuse[T]) MaxKeys() uint32 {
+	numFingerprints := uint64(len(filter.Fingerprints))
+	// The number of fingerprints grows with the number of keys, so that a
+	// binary search applies. The upper bound keeps the capacity computation
+	// within 32 bits.
+	lo, hi := uint32(0), uint32(math.MaxUint32/2)
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		var f BinaryFuse[T]
+		if uint64(f.setParameters(mid)) <= numFingerprints {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
 
-	// Allocate fingerprints slice.
-	numFingerprints := totalSegmentCount * filter.SegmentLength
+// allocateFingerprints sets the Fingerprints slice to numFingerprints zeroed
+// entries backed by the builder's buffer.
+func (filter *BinaryFuse[T]) allocateFingerprints(b *BinaryFuseBuilder, numFingerprints uint32) {
 	// Our backing buffer is a []uint32. Figure out how many uint32s we need
 	// to back a []T of the requested size.
 	bufSize := (numFingerprints*uint32(unsafe.Sizeof(T(0))) + 3) / 4
@@ -26,7 +26,8 @@ func (f *BinaryFuse8) Save(w io.Writer) error {
 	return (*BinaryFuse[uint8])(f).Save(w)
 }
 
-// LoadBinaryFuse8 reads the filter from the reader in little endian format.
+// LoadBinaryFuse8 reads the filter, saved by Save or SaveCompressed, from the
+// reader in little endian format.
 func LoadBinaryFuse8(r io.Reader) (*BinaryFuse8, error) {
 	filter, err := LoadBinaryFuse[uint8](r)
 	if err != nil {
@@ -126,6 +126,47 @@ func TestBinaryFuseN_DuplicateKeysBinaryFuseDup(t *testing.T) {
 	}
 }
 
+// duplicatedKeys returns n distinct keys, each repeated three times, in
+// random order.
+func duplicatedKeys(n int) []uint64 {
+	keys := make([]uint64, 0, 3*n)
+	for range n {
+		key := rand.Uint64()
+		keys = append(keys, key, key, key)
+	}
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	return keys
+}
+
+func TestBuildKeepsKeysUnchanged(t *testing.T) {
+	keys := duplicatedKeys(10000)
+	original := slices.Clone(keys)
+
+	var b BinaryFuseBuilder
+	for range 2 {
+		filter, err := BuildBinaryFuse[uint8](&b, keys)
+		require.NoError(t, err)
+		require.Equal(t, original, keys)
+		for _, key := range keys {
+			require.True(t, filter.Contains(key))
+		}
+	}
+
+	packed, err := NewBinaryFusePacked(keys, 12)
+	require.NoError(t, err)
+	require.Equal(t, original, keys)
+	for _, key := range keys {
+		require.True(t, packed.Contains(key))
+	}
+
+	xor, err := Populate(keys)
+	require.NoError(t, err)
+	require.Equal(t, original, keys)
+	for _, key := range keys {
+		require.True(t, xor.Contains(key))
+	}
+}
+
 func TestBinaryFuseN_DuplicateKeysBinaryFuseDup_Issue30(t *testing.T) {
 	keys := []uint64{
 		14032282262966018013,
@@ -330,6 +371,24 @@ func BenchmarkBinaryFuseNContains50000000(b *testing.B) {
 	}
 }
 
+var binaryfuseblockedbig *BinaryFuseBlocked[uint8]
+
+func BenchmarkBinaryFuseBlockedContains50000000(b *testing.B) {
+	if binaryfuseblockedbig == nil {
+		fmt.Println("Blocked Binary Fuse setup")
+		keys := make([]uint64, 50000000)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		binaryfuseblockedbig, _ = NewBinaryFuseBlocked[uint8](keys)
+		fmt.Println("Blocked Binary Fuse setup ok")
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bogusbool = binaryfuseblockedbig.Contains(rand.Uint64())
+	}
+}
+
 func TestBinaryFuseN_Issue35(t *testing.T) {
 	for test := 0; test < 100; test++ {
 		hashes := make([]uint64, 0)
@@ -549,3 +608,26 @@ func TestBinaryFuseBoundarySizes(t *testing.T) {
 		checkNumIterations(t, s.endSize)
 	}
 }
+
+func TestBinaryFuseValidate(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 10, 1000, 12345, 1_000_000} {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		filter, err := NewBinaryFuse[uint16](keys)
+		require.NoError(t, err)
+		require.NoError(t, filter.Validate())
+		require.GreaterOrEqual(t, filter.MaxKeys(), uint32(n))
+
+		var larger BinaryFuse[uint16]
+		require.Greater(t, larger.setParameters(filter.MaxKeys()+1), uint32(len(filter.Fingerprints)))
+
+		corrupt := *filter
+		corrupt.SegmentLengthMask++
+		require.Error(t, corrupt.Validate())
+		corrupt = *filter
+		corrupt.Fingerprints = corrupt.Fingerprints[:len(corrupt.Fingerprints)-1]
+		require.Error(t, corrupt.Validate())
+	}
+}
@@ -0,0 +1,138 @@
+package xorfilter
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// BinaryFusePacked is a binary fuse filter with bit-packed fingerprints whose
+// width is chosen at construction time, between 1 and 32 bits. A width of b
+// bits gives a false positive probability of about 2^-b and uses about
+// 1.125*b bits per key for sizeable sets, so it covers the trade-offs between
+// those of BinaryFuse[uint8], BinaryFuse[uint16] and BinaryFuse[uint32].
+type BinaryFusePacked struct {
+	Seed               uint64
+	SegmentLength      uint32
+	SegmentLengthMask  uint32
+	SegmentCount       uint32
+	SegmentCountLength uint32
+	Bits               uint32
+
+	// Fingerprints holds the fingerprints, Bits bits each, starting with the
+	// least significant bits of the first word. The last word is padding so
+	// that any fingerprint can be read with two loads.
+	Fingerprints []uint64
+}
+
+// NewBinaryFusePacked creates a binary fuse filter with provided keys and
+// fingerprints of the given width in bits (1 to 32). For best results, the
+// caller should avoid having too many duplicated keys.
+//
+// The keys slice is not modified: duplicates are removed in a copy.
+func NewBinaryFusePacked(keys []uint64, width int) (*BinaryFusePacked, error) {
+	var b BinaryFuseBuilder
+	filter, err := BuildBinaryFusePacked(&b, keys, width)
+	if err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// BuildBinaryFusePacked creates a bit-packed binary fuse filter with provided
+// keys, reusing buffers from the BinaryFuseBuilder if possible. The keys are
+// peeled exactly as in BuildBinaryFuse: with a width of 8 or 16 bits, the
+// fingerprints are the same as those of BinaryFuse[uint8] or
+// BinaryFuse[uint16].
+//
+// The Fingerprints slice in the resulting filter is owned by the builder; it
+// is only valid until the BinaryFuseBuilder is used again.
+//
+// The keys slice is not modified: duplicates are removed in a copy owned by
+// the builder.
+func BuildBinaryFusePacked(b *BinaryFuseBuilder, keys []uint64, width int) (BinaryFusePacked, error) {
+	if width < 1 || width > 32 {
+		return BinaryFusePacked{}, errors.New("fingerprint width must be between 1 and 32 bits")
+	}
+	// The segment layout and the peeling order do not depend on the
+	// fingerprint type.
+	var layout BinaryFuse[uint32]
+	size, capacity, _, err := layout.peel(b, keys, nil)
+	if err != nil {
+		return BinaryFusePacked{}, err
+	}
+	filter := BinaryFusePacked{
+		Seed:               layout.Seed,
+		SegmentLength:      layout.SegmentLength,
+		SegmentLengthMask:  layout.SegmentLengthMask,
+		SegmentCount:       layout.SegmentCount,
+		SegmentCountLength: layout.SegmentCountLength,
+		Bits:               uint32(width),
+	}
+	filter.Fingerprints = reuseBuffer(&b.packed, packedWords(capacity, filter.Bits))
+
+	reverseOrder := b.reverseOrder
+	reverseH := b.reverseH
+	mask := filter.mask()
+	var h012 [5]uint32
+	for i := int(size) - 1; i >= 0; i-- {
+		hash := reverseOrder[i]
+		index1, index2, index3 := filter.getHashFromHash(hash)
+		found := reverseH[i]
+		h012[0] = index1
+		h012[1] = index2
+		h012[2] = index3
+		h012[3] = h012[0]
+		h012[4] = h012[1]
+		// The slot being assigned is still zero, so xoring the value in is
+		// the same as storing it.
+		filter.xorAt(h012[found], (fingerprint(hash)^filter.load(h012[found+1])^filter.load(h012[found+2]))&mask)
+	}
+	return filter, nil
+}
+
+// packedWords returns the number of words needed to store numFingerprints
+// fingerprints of the given width, including the padding word.
+func packedWords(numFingerprints, width uint32) uint32 {
+	return uint32((uint64(numFingerprints)*uint64(width)+63)/64) + 1
+}
+
+func (filter *BinaryFusePacked) mask() uint64 {
+	return uint64(1)<<filter.Bits - 1
+}
+
+// load returns the fingerprint at the given index in the low Bits bits; the
+// higher bits are garbage and must be masked by the caller.
+func (filter *BinaryFusePacked) load(index uint32) uint64 {
+	pos := uint64(index) * uint64(filter.Bits)
+	word, shift := pos/64, pos%64
+	// When shift is 0, the second shift count is 64 and the second word
+	// contributes nothing.
+	return filter.Fingerprints[word]>>shift | filter.Fingerprints[word+1]<<(64-shift)
+}
+
+// xorAt xors the masked value v into the fingerprint at the given index.
+func (filter *BinaryFusePacked) xorAt(index uint32, v uint64) {
+	pos := uint64(index) * uint64(filter.Bits)
+	word, shift := pos/64, pos%64
+	filter.Fingerprints[word] ^= v << shift
+	filter.Fingerprints[word+1] ^= v >> (64 - shift)
+}
+
+func (filter *BinaryFusePacked) getHashFromHash(hash uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(hash, uint64(filter.SegmentCountLength))
+	h0 := uint32(hi)
+	h1 := h0 + filter.SegmentLength
+	h2 := h1 + filter.SegmentLength
+	h1 ^= uint32(hash>>18) & filter.SegmentLengthMask
+	h2 ^= uint32(hash) & filter.SegmentLengthMask
+	return h0, h1, h2
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability of about 2^-Bits.
+func (filter *BinaryFusePacked) Contains(key uint64) bool {
+	hash := mixsplit(key, filter.Seed)
+	h0, h1, h2 := filter.getHashFromHash(hash)
+	f := fingerprint(hash) ^ filter.load(h0) ^ filter.load(h1) ^ filter.load(h2)
+	return f&filter.mask() == 0
+}
@@ -0,0 +1,129 @@
+package xorfilter
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryFusePackedWidths(t *testing.T) {
+	keys := make([]uint64, 100_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	for _, bits := range []int{1, 4, 7, 10, 12, 20, 32} {
+		filter, err := NewBinaryFusePacked(keys, bits)
+		require.NoError(t, err)
+		for _, v := range keys {
+			require.True(t, filter.Contains(v), "bits=%d", bits)
+		}
+		falsesize := 1_000_000
+		matches := 0
+		for i := 0; i < falsesize; i++ {
+			if filter.Contains(rand.Uint64()) {
+				matches++
+			}
+		}
+		fpp := float64(matches) / float64(falsesize)
+		expected := math.Pow(2, -float64(bits))
+		bpv := float64(len(filter.Fingerprints)) * 64.0 / float64(len(keys))
+		t.Logf("bits=%d bits per entry %.2f false positive rate %.6f (expected %.6f)", bits, bpv, fpp, expected)
+		assert.Less(t, fpp, 1.5*expected+10.0/float64(falsesize))
+	}
+}
+
+// TestBinaryFusePackedMatchesBinaryFuse verifies that the packed filter uses
+// the same layout and fingerprints as the generic filter.
+func TestBinaryFusePackedMatchesBinaryFuse(t *testing.T) {
+	keys := make([]uint64, 1+rand.IntN(50_000))
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	packed8, err := NewBinaryFusePacked(slices.Clone(keys), 8)
+	require.NoError(t, err)
+	filter8, err := NewBinaryFuse[uint8](slices.Clone(keys))
+	require.NoError(t, err)
+	require.Equal(t, filter8.Seed, packed8.Seed)
+	require.Equal(t, filter8.SegmentCountLength, packed8.SegmentCountLength)
+	for i, fp := range filter8.Fingerprints {
+		require.Equal(t, uint64(fp), packed8.load(uint32(i))&packed8.mask())
+	}
+
+	packed16, err := NewBinaryFusePacked(slices.Clone(keys), 16)
+	require.NoError(t, err)
+	filter16, err := NewBinaryFuse[uint16](slices.Clone(keys))
+	require.NoError(t, err)
+	for i, fp := range filter16.Fingerprints {
+		require.Equal(t, uint64(fp), packed16.load(uint32(i))&packed16.mask())
+	}
+}
+
+func TestBinaryFusePackedInvalidWidth(t *testing.T) {
+	for _, bits := range []int{-1, 0, 33, 64} {
+		_, err := NewBinaryFusePacked([]uint64{1, 2, 3}, bits)
+		require.Error(t, err)
+	}
+}
+
+func TestBinaryFusePackedSmall(t *testing.T) {
+	var b BinaryFuseBuilder
+	for _, n := range []int{0, 1, 2, 3, 10, 100} {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		filter, err := BuildBinaryFusePacked(&b, keys, 10)
+		require.NoError(t, err)
+		for _, v := range keys {
+			require.True(t, filter.Contains(v))
+		}
+	}
+}
+
+func TestBinaryFusePackedSerialization(t *testing.T) {
+	keys := []uint64{1, 2, 3, 4, 5, 100, 200, 300}
+	filter, err := NewBinaryFusePacked(keys, 12)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, filter.Save(&buf))
+	loaded, err := LoadBinaryFusePacked(&buf)
+	require.NoError(t, err)
+	require.Equal(t, filter, loaded)
+	for _, key := range keys {
+		require.True(t, loaded.Contains(key))
+	}
+}
+
+func BenchmarkBinaryFusePackedContains1000000(b *testing.B) {
+	keys := make([]uint64, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, _ := NewBinaryFusePacked(keys, 12)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bogusbool = filter.Contains(keys[n%len(keys)])
+	}
+}
+
+func TestLoadBinaryFusePackedCorrupt(t *testing.T) {
+	filter, err := NewBinaryFusePacked([]uint64{1, 2, 3, 4, 5, 100, 200, 300}, 12)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, filter.Save(&buf))
+	// Header offsets: SegmentLength 8, SegmentLengthMask 12, SegmentCount 16,
+	// SegmentCountLength 20.
+	for _, offset := range []int{8, 12, 16, 20} {
+		data := bytes.Clone(buf.Bytes())
+		data[offset] ^= 0x40
+		_, err := LoadBinaryFusePacked(bytes.NewReader(data))
+		require.Error(t, err, "offset %d", offset)
+	}
+}
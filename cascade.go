@@ -0,0 +1,146 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// maxCascadeLevels bounds the depth of a cascade. Each level holds about
+// 1/256 of the keys of the level two steps above, so a few levels suffice in
+// practice.
+const maxCascadeLevels = 64
+
+// Cascade is a filter cascade: it answers membership queries exactly over a
+// known universe of keys (the included and excluded keys it was built from),
+// as used by CRLite for certificate revocation. Queries for keys outside of
+// the universe have a false positive probability of <0.4%.
+//
+// Level 0 is a BinaryFuse8 over the included keys. Each following level is
+// built over the false positives of the previous level, alternating between
+// excluded and included keys, until there are no false positives left. Keys
+// are remapped differently at each level so that the false positives of
+// consecutive levels are independent.
+type Cascade struct {
+	Levels []*BinaryFuse8
+}
+
+// NewCascade builds a cascade that returns true for the included keys and
+// false for the excluded keys. The two sets must be disjoint. The given
+// slices are not modified.
+func NewCascade(included, excluded []uint64) (*Cascade, error) {
+	in := slices.Clone(included)
+	out := slices.Clone(excluded)
+	if len(in) > 0 {
+		in = pruneDuplicates(in)
+	}
+	if len(out) > 0 {
+		out = pruneDuplicates(out)
+	}
+	if sortedIntersect(in, out) {
+		return nil, errors.New("included and excluded keys overlap")
+	}
+
+	var b BinaryFuseBuilder
+	c := &Cascade{}
+	for len(in) > 0 {
+		if len(c.Levels) == maxCascadeLevels {
+			return nil, errors.New("too many cascade levels")
+		}
+		levelKeys := make([]uint64, len(in))
+		for i, key := range in {
+			levelKeys[i] = cascadeKey(key, len(c.Levels))
+		}
+		filter, err := buildOwned[uint8](&b, levelKeys)
+		if err != nil {
+			return nil, err
+		}
+		level := (*BinaryFuse8)(&filter)
+		c.Levels = append(c.Levels, level)
+
+		var falsePositives []uint64
+		for _, key := range out {
+			if level.Contains(cascadeKey(key, len(c.Levels)-1)) {
+				falsePositives = append(falsePositives, key)
+			}
+		}
+		in, out = falsePositives, in
+	}
+	return c, nil
+}
+
+// cascadeKey maps key to the key stored at the given level. Without it, two
+// small levels over the same keys would be identical, and a pair of keys that
+// collide in one of them would make the cascade alternate forever.
+func cascadeKey(key uint64, level int) uint64 {
+	return mixsplit(key, uint64(level))
+}
+
+// sortedIntersect reports whether two sorted slices have a common element.
+func sortedIntersect(a, b []uint64) bool {
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns `true` if key is one of the included keys and `false` if
+// it is one of the excluded keys.
+func (c *Cascade) Contains(key uint64) bool {
+	for i, level := range c.Levels {
+		if !level.Contains(cascadeKey(key, i)) {
+			// Odd levels are built over excluded keys.
+			return i%2 == 1
+		}
+	}
+	return len(c.Levels)%2 == 1
+}
+
+// Save writes the number of levels followed by each level to the writer in
+// little endian format.
+func (c *Cascade) Save(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(c.Levels))); err != nil {
+		return err
+	}
+	for _, level := range c.Levels {
+		if err := level.Save(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadCascade reads the cascade from the reader in little endian format.
+func LoadCascade(r io.Reader) (*Cascade, error) {
+	return LoadCascadeWithOptions(r, LoadOptions{})
+}
+
+// LoadCascadeWithOptions is LoadCascade with restrictions on the cascades it
+// accepts. LoadOptions.MaxBytes bounds the size of all the levels together.
+func LoadCascadeWithOptions(r io.Reader, opts LoadOptions) (*Cascade, error) {
+	budget := opts.budget()
+	var numLevels uint32
+	if err := binary.Read(r, binary.LittleEndian, &numLevels); err != nil {
+		return nil, err
+	}
+	if numLevels > maxCascadeLevels {
+		return nil, errors.New("too many cascade levels")
+	}
+	c := &Cascade{Levels: make([]*BinaryFuse8, numLevels)}
+	for i := range c.Levels {
+		level, err := loadBinaryFuse[uint8](r, budget)
+		if err != nil {
+			return nil, err
+		}
+		c.Levels[i] = (*BinaryFuse8)(level)
+	}
+	return c, nil
+}
@@ -0,0 +1,60 @@
+package xorfilter
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCascade(t *testing.T) {
+	included := make([]uint64, 10_000)
+	for i := range included {
+		included[i] = rand.Uint64()
+	}
+	excluded := make([]uint64, 200_000)
+	for i := range excluded {
+		excluded[i] = rand.Uint64()
+	}
+	includedCopy := slices.Clone(included)
+	c, err := NewCascade(included, excluded)
+	require.NoError(t, err)
+	require.Equal(t, includedCopy, included)
+	t.Logf("levels: %d", len(c.Levels))
+
+	for _, key := range included {
+		require.True(t, c.Contains(key))
+	}
+	for _, key := range excluded {
+		require.False(t, c.Contains(key))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Save(&buf))
+	loaded, err := LoadCascade(&buf)
+	require.NoError(t, err)
+	require.Equal(t, c, loaded)
+	for _, key := range excluded {
+		require.False(t, loaded.Contains(key))
+	}
+}
+
+func TestCascadeEdgeCases(t *testing.T) {
+	c, err := NewCascade(nil, []uint64{1, 2, 3})
+	require.NoError(t, err)
+	require.False(t, c.Contains(1))
+
+	c, err = NewCascade([]uint64{1, 2, 3, 3}, nil)
+	require.NoError(t, err)
+	require.True(t, c.Contains(3))
+
+	_, err = NewCascade([]uint64{1, 2, 3}, []uint64{3, 4})
+	require.Error(t, err)
+}
+
+func TestLoadCascadeTooManyLevels(t *testing.T) {
+	_, err := LoadCascade(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))
+	require.Error(t, err)
+}
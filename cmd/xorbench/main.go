@@ -0,0 +1,325 @@
+// Command xorbench compares the filters of the xorfilter package on a key
+// file: construction time, query time for present and absent keys, memory
+// usage and measured false positive rate.
+//
+// Usage:
+//
+//	xorbench [-format uint|hex|string] [-hash xxhash|fnv1a] [-negatives file] [-filters list] [-output table|csv|json] keys.txt
+//
+// Without a negatives file, the absent keys are random 64-bit keys that are
+// not in the key file. The keys of a negatives file that are also in the key
+// file are ignored, since accepting them is not a false positive.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unsafe"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/FastFilter/xorfilter/internal/keyfile"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Result holds the measurements for one filter.
+type Result struct {
+	Filter string `json:"filter"`
+	Keys   int    `json:"keys"`
+	// BuildNanos is the fastest construction time over the runs.
+	BuildNanos    int64   `json:"build_ns"`
+	PositiveNanos float64 `json:"positive_ns_per_query"`
+	NegativeNanos float64 `json:"negative_ns_per_query"`
+	Bytes         int     `json:"bytes"`
+	BitsPerKey    float64 `json:"bits_per_key"`
+	FalsePositive float64 `json:"false_positive_rate"`
+}
+
+// candidate builds one kind of filter. The keys may be reordered.
+type candidate struct {
+	name  string
+	build func(keys []uint64) (contains func(uint64) bool, size int, err error)
+}
+
+func fuse[T xorfilter.Unsigned](keys []uint64) (func(uint64) bool, int, error) {
+	f, err := xorfilter.NewBinaryFuse[T](keys)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f.Contains, fuseSize(f), nil
+}
+
+func fuseSize[T xorfilter.Unsigned](f *xorfilter.BinaryFuse[T]) int {
+	return int(unsafe.Sizeof(*f)) + len(f.Fingerprints)*int(unsafe.Sizeof(T(0)))
+}
+
+func blocked[T xorfilter.Unsigned](keys []uint64) (func(uint64) bool, int, error) {
+	f, err := xorfilter.NewBinaryFuseBlocked[T](keys)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := int(unsafe.Sizeof(*f)) + len(f.Fingerprints)*int(unsafe.Sizeof(T(0)))
+	if f.Overflow != nil {
+		size += fuseSize(f.Overflow)
+	}
+	return f.Contains, size, nil
+}
+
+func packed(width int) func(keys []uint64) (func(uint64) bool, int, error) {
+	return func(keys []uint64) (func(uint64) bool, int, error) {
+		f, err := xorfilter.NewBinaryFusePacked(keys, width)
+		if err != nil {
+			return nil, 0, err
+		}
+		return f.Contains, int(unsafe.Sizeof(*f)) + 8*len(f.Fingerprints), nil
+	}
+}
+
+func xor8(keys []uint64) (func(uint64) bool, int, error) {
+	f, err := xorfilter.Populate(keys)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f.Contains, int(unsafe.Sizeof(*f)) + len(f.Fingerprints), nil
+}
+
+var candidates = []candidate{
+	{"xor8", xor8},
+	{"fuse8", fuse[uint8]},
+	{"fuse16", fuse[uint16]},
+	{"fuse32", fuse[uint32]},
+	{"packed12", packed(12)},
+	{"blocked8", blocked[uint8]},
+	{"blocked16", blocked[uint16]},
+}
+
+func candidateNames() []string {
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c.name
+	}
+	return names
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("xorbench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", string(keyfile.Decimal), "key format: uint, hex or string")
+	hasher := fs.String("hash", "xxhash", "hash function for string keys: xxhash or fnv1a")
+	negativesPath := fs.String("negatives", "", "file of absent keys, in the same format (default: random keys)")
+	numNegatives := fs.Int("random", 1_000_000, "number of random absent keys when no negatives file is given")
+	filters := fs.String("filters", strings.Join(candidateNames(), ","), "comma-separated filters to compare")
+	output := fs.String("output", "table", "output format: table, csv or json")
+	runs := fs.Int("runs", 3, "number of constructions and query passes; the fastest is reported")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || *runs < 1 {
+		fs.Usage()
+		return 2
+	}
+	if err := benchmark(fs.Arg(0), *format, *hasher, *negativesPath, *numNegatives, *filters, *output, *runs, stdout); err != nil {
+		fmt.Fprintf(stderr, "xorbench: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func benchmark(path, format, hasher, negativesPath string, numNegatives int, filters, output string, runs int, stdout io.Writer) error {
+	var selected []candidate
+	for _, name := range strings.Split(filters, ",") {
+		i := slices.IndexFunc(candidates, func(c candidate) bool { return c.name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown filter %q, expected one of %s", name, strings.Join(candidateNames(), ","))
+		}
+		selected = append(selected, candidates[i])
+	}
+	write, err := writer(output)
+	if err != nil {
+		return err
+	}
+	parser, err := keyfile.NewParser(keyfile.Format(format), hasher)
+	if err != nil {
+		return err
+	}
+	keys, err := readKeys(parser, path)
+	if err != nil {
+		return err
+	}
+	// Duplicates would distort the bits per key.
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	if len(keys) == 0 {
+		return errors.New("no keys")
+	}
+
+	var negatives []uint64
+	if negativesPath != "" {
+		if negatives, err = readKeys(parser, negativesPath); err != nil {
+			return err
+		}
+		negatives = slices.DeleteFunc(negatives, func(key uint64) bool {
+			_, found := slices.BinarySearch(keys, key)
+			return found
+		})
+		if len(negatives) == 0 {
+			return fmt.Errorf("all the keys of %s are in the key file", negativesPath)
+		}
+	} else {
+		negatives = make([]uint64, 0, numNegatives)
+		for len(negatives) < numNegatives {
+			key := rand.Uint64()
+			if _, found := slices.BinarySearch(keys, key); !found {
+				negatives = append(negatives, key)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(selected))
+	for _, c := range selected {
+		r, err := measure(c, keys, negatives, runs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		results = append(results, r)
+	}
+	return write(stdout, results)
+}
+
+func readKeys(parser *keyfile.Parser, path string) ([]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parser.ReadAll(file)
+}
+
+func measure(c candidate, keys, negatives []uint64, runs int) (Result, error) {
+	r := Result{Filter: c.name, Keys: len(keys)}
+	scratch := make([]uint64, len(keys))
+	var contains func(uint64) bool
+	for i := 0; i < runs; i++ {
+		copy(scratch, keys)
+		start := time.Now()
+		f, size, err := c.build(scratch)
+		elapsed := time.Since(start).Nanoseconds()
+		if err != nil {
+			return r, err
+		}
+		if i == 0 || elapsed < r.BuildNanos {
+			r.BuildNanos = elapsed
+		}
+		contains, r.Bytes = f, size
+	}
+	r.BitsPerKey = float64(r.Bytes) * 8 / float64(len(keys))
+
+	for _, key := range keys {
+		if !contains(key) {
+			return r, fmt.Errorf("false negative for key %d", key)
+		}
+	}
+	falsePositives := 0
+	for _, key := range negatives {
+		if contains(key) {
+			falsePositives++
+		}
+	}
+	if len(negatives) > 0 {
+		r.FalsePositive = float64(falsePositives) / float64(len(negatives))
+	}
+	r.PositiveNanos = queryTime(contains, keys, runs)
+	r.NegativeNanos = queryTime(contains, negatives, runs)
+	return r, nil
+}
+
+// sink keeps the query loops from being optimized away.
+var sink int
+
+// queryTime returns the fastest time per query over the runs.
+func queryTime(contains func(uint64) bool, keys []uint64, runs int) float64 {
+	if len(keys) == 0 {
+		return 0
+	}
+	best := 0.0
+	for i := 0; i < runs; i++ {
+		count := 0
+		start := time.Now()
+		for _, key := range keys {
+			if contains(key) {
+				count++
+			}
+		}
+		elapsed := float64(time.Since(start).Nanoseconds()) / float64(len(keys))
+		sink += count
+		if i == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	return best
+}
+
+func writer(output string) (func(io.Writer, []Result) error, error) {
+	switch output {
+	case "table":
+		return writeTable, nil
+	case "csv":
+		return writeCSV, nil
+	case "json":
+		return writeJSON, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", output)
+}
+
+var columns = []string{"filter", "keys", "build_ms", "positive_ns", "negative_ns", "bytes", "bits_per_key", "fpr"}
+
+func (r Result) fields() []string {
+	return []string{
+		r.Filter,
+		strconv.Itoa(r.Keys),
+		strconv.FormatFloat(float64(r.BuildNanos)/1e6, 'f', 2, 64),
+		strconv.FormatFloat(r.PositiveNanos, 'f', 1, 64),
+		strconv.FormatFloat(r.NegativeNanos, 'f', 1, 64),
+		strconv.Itoa(r.Bytes),
+		strconv.FormatFloat(r.BitsPerKey, 'f', 2, 64),
+		strconv.FormatFloat(r.FalsePositive, 'g', 4, 64),
+	}
+}
+
+func writeTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, strings.Join(columns, "\t")+"\t")
+	for _, r := range results {
+		fmt.Fprintln(tw, strings.Join(r.fields(), "\t")+"\t")
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	for _, r := range results {
+		cw.Write(r.fields())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
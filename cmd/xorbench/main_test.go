@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeys(t *testing.T, n int) string {
+	t.Helper()
+	var keys strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&keys, "key-%d\n", i%(n/2))
+	}
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(path, []byte(keys.String()), 0o644))
+	return path
+}
+
+func TestBenchmarkOutputs(t *testing.T) {
+	path := writeKeys(t, 20000)
+	args := []string{"-format", "string", "-random", "100000", "-runs", "1"}
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run(append(args, "-output", "json", path), &stdout, &stderr), stderr.String())
+	var results []Result
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, len(candidates))
+	for _, r := range results {
+		// Duplicated lines are counted once.
+		require.Equal(t, 10000, r.Keys, r.Filter)
+		require.Positive(t, r.Bytes, r.Filter)
+		require.Positive(t, r.BitsPerKey, r.Filter)
+	}
+	fpr := map[string]float64{}
+	for _, r := range results {
+		fpr[r.Filter] = r.FalsePositive
+	}
+	require.InDelta(t, 1.0/256, fpr["fuse8"], 0.002)
+	require.Less(t, fpr["fuse16"], 0.0005)
+
+	stdout.Reset()
+	require.Equal(t, 0, run(append(args, "-output", "csv", "-filters", "fuse8,xor8", path), &stdout, &stderr), stderr.String())
+	records, err := csv.NewReader(&stdout).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, columns, records[0])
+	require.Equal(t, "fuse8", records[1][0])
+	require.Equal(t, "xor8", records[2][0])
+
+	stdout.Reset()
+	require.Equal(t, 0, run(append(args, "-filters", "blocked8", path), &stdout, &stderr), stderr.String())
+	require.Contains(t, stdout.String(), "blocked8")
+	require.Contains(t, stdout.String(), "bits_per_key")
+}
+
+func TestBenchmarkNegativesFile(t *testing.T) {
+	path := writeKeys(t, 2000)
+	// Half of the negatives are keys, which are not false positives.
+	negatives := writeKeys(t, 4000)
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-format", "string", "-negatives", negatives, "-filters", "fuse32", "-output", "json", "-runs", "1", path}, &stdout, &stderr)
+	require.Equal(t, 0, status, stderr.String())
+	var results []Result
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Equal(t, 0.0, results[0].FalsePositive)
+
+	// Negatives that are all keys cannot measure anything.
+	stdout.Reset()
+	status = run([]string{"-format", "string", "-negatives", path, "-filters", "fuse32", path}, &stdout, &stderr)
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr.String(), "are in the key file")
+}
+
+func TestBenchmarkErrors(t *testing.T) {
+	path := writeKeys(t, 100)
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 1, run([]string{"-filters", "bloom", path}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "unknown filter")
+	require.Equal(t, 1, run([]string{"-output", "xml", "-format", "string", path}, &stdout, &stderr))
+	require.Equal(t, 1, run([]string{path}, &stdout, &stderr))
+	require.Equal(t, 2, run(nil, &stdout, &stderr))
+}
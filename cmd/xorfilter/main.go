@@ -0,0 +1,430 @@
+// Command xorfilter builds, inspects and queries binary fuse filter files
+// written by BinaryFuse[T].Save or SaveCompressed.
+//
+// Usage:
+//
+//	xorfilter build [-in keys.txt] [-format uint|hex|string] [-hash xxhash|fnv1a] [-bits 8|16|32] -out filter.bin
+//	xorfilter info filter.bin
+//	xorfilter query [-format ...] [-hash ...] filter.bin < keys.txt
+//	xorfilter verify [-sha256 hex] [-keys keys.txt -format ... -hash ...] filter.bin
+//
+// Keys are read one per line. The fingerprint width of a filter file is
+// deduced from its size, or from the length of its decompressed fingerprints.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/FastFilter/xorfilter/internal/keyfile"
+)
+
+const usage = `usage:
+  xorfilter build [-in keys.txt] [-format uint|hex|string] [-hash xxhash|fnv1a] [-bits 8|16|32] -out filter.bin
+  xorfilter info filter.bin
+  xorfilter query [-format uint|hex|string] [-hash xxhash|fnv1a] filter.bin < keys.txt
+  xorfilter verify [-sha256 hex] [-keys keys.txt] [-format ...] [-hash ...] filter.bin
+`
+
+// headerSize is the size of the header written by BinaryFuse[T].Save: the
+// seed, four segment parameters and the number of fingerprints.
+const headerSize = 8 + 5*4
+
+// Filters written by SaveCompressed and KeyedBinaryFuse.Save have these
+// markers where the segment length would be.
+const (
+	compressedMarker = 0
+	keyedMarker      = 0xFFFFFFFF
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "build":
+		err = build(args[1:], stdin, stdout)
+	case "info":
+		err = info(args[1:], stdout)
+	case "query":
+		err = query(args[1:], stdin, stdout)
+	case "verify":
+		err = verify(args[1:], stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "xorfilter %s: %v\n", args[0], err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+// keyFlags registers the flags describing the key format.
+func keyFlags(fs *flag.FlagSet) (format, hasher *string) {
+	format = fs.String("format", string(keyfile.Decimal), "key format: uint, hex or string")
+	hasher = fs.String("hash", "xxhash", "hash function for string keys: xxhash or fnv1a")
+	return format, hasher
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string, numArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+	if fs.NArg() != numArgs {
+		return usageError{fmt.Sprintf("expected %d argument(s), got %d", numArgs, fs.NArg())}
+	}
+	return nil
+}
+
+func build(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("build")
+	in := fs.String("in", "-", "key file, or - for standard input")
+	out := fs.String("out", "", "output filter file")
+	width := fs.Int("bits", 8, "fingerprint width: 8, 16 or 32")
+	format, hasher := keyFlags(fs)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{"missing -out"}
+	}
+	parser, err := keyfile.NewParser(keyfile.Format(*format), *hasher)
+	if err != nil {
+		return err
+	}
+	r := stdin
+	if *in != "-" {
+		file, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	keys, err := parser.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	numKeys := len(keys)
+
+	var buf bytes.Buffer
+	switch *width {
+	case 8:
+		err = buildTo[uint8](&buf, keys)
+	case 16:
+		err = buildTo[uint16](&buf, keys)
+	case 32:
+		err = buildTo[uint32](&buf, keys)
+	default:
+		return usageError{fmt.Sprintf("unsupported fingerprint width %d", *width)}
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(*out, buf.Bytes()); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	fmt.Fprintf(stdout, "keys: %d\n", numKeys)
+	fmt.Fprintf(stdout, "size: %d bytes\n", buf.Len())
+	if numKeys > 0 {
+		fmt.Fprintf(stdout, "bits/key: %.2f\n", float64(buf.Len())*8/float64(numKeys))
+	}
+	fmt.Fprintf(stdout, "sha256: %s\n", hex.EncodeToString(sum[:]))
+	return nil
+}
+
+func buildTo[T xorfilter.Unsigned](w io.Writer, keys []uint64) error {
+	filter, err := xorfilter.NewBinaryFuse[T](keys)
+	if err != nil {
+		return err
+	}
+	return filter.Save(w)
+}
+
+// writeFileAtomic writes the file through a temporary file in the same
+// directory, so that readers never see a partial filter.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// filterFile is a loaded filter file.
+type filterFile struct {
+	data            []byte
+	width           int
+	seed            uint64
+	segmentLength   uint32
+	segmentCount    uint32
+	numFingerprints int
+	filter          interface {
+		Contains(key uint64) bool
+		Validate() error
+		MaxKeys() uint32
+	}
+}
+
+func openFilter(path string) (*filterFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseFilter(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid structure: %w", err)
+	}
+	return f, nil
+}
+
+func parseFilter(data []byte) (*filterFile, error) {
+	if len(data) < 12 {
+		return nil, errors.New("file too short for a filter header")
+	}
+	switch binary.LittleEndian.Uint32(data[8:]) {
+	case compressedMarker:
+		return parseCompressed(data)
+	case keyedMarker:
+		return nil, errors.New("keyed filter (KeyedBinaryFuse): its secret key is required, which this tool does not support")
+	}
+	if len(data) < headerSize {
+		return nil, otherFormat(data, errors.New("file too short for a filter header"))
+	}
+	fpLen := uint64(binary.LittleEndian.Uint32(data[headerSize-4:]))
+	payload := uint64(len(data) - headerSize)
+	if fpLen == 0 || payload%fpLen != 0 {
+		return nil, otherFormat(data, fmt.Errorf("%d bytes of fingerprints do not match %d fingerprints", payload, fpLen))
+	}
+	var f *filterFile
+	var err error
+	switch payload / fpLen {
+	case 1:
+		f, err = loadFilter[uint8](data, 8)
+	case 2:
+		f, err = loadFilter[uint16](data, 16)
+	case 4:
+		f, err = loadFilter[uint32](data, 32)
+	default:
+		err = fmt.Errorf("unsupported fingerprint size of %d bytes", payload/fpLen)
+	}
+	if err != nil {
+		return nil, otherFormat(data, err)
+	}
+	return f, nil
+}
+
+// parseCompressed reads a filter written by SaveCompressed. The fingerprint
+// width is not part of the header: it is the one with which the compressed
+// fingerprints have the declared length.
+func parseCompressed(data []byte) (*filterFile, error) {
+	if f, err := loadFilter[uint8](data, 8); err == nil {
+		return f, nil
+	}
+	if f, err := loadFilter[uint16](data, 16); err == nil {
+		return f, nil
+	}
+	f, err := loadFilter[uint32](data, 32)
+	if err != nil {
+		if _, xorErr := xorfilter.LoadXor8(bytes.NewReader(data)); xorErr == nil {
+			return nil, errors.New("compressed Xor8 filter, which this tool does not support")
+		}
+		return nil, fmt.Errorf("compressed filter: %w", err)
+	}
+	return f, nil
+}
+
+// otherFormat returns the error explaining that data is not a filter written
+// by BinaryFuse[T].Save, naming the format of data when it is another one of
+// the package.
+func otherFormat(data []byte, err error) error {
+	if len(data) >= 4 {
+		switch string(data[:4]) {
+		case "XFLT":
+			return errors.New("filter written by SaveFilter, which this tool does not support")
+		case "XPAK":
+			return errors.New("filter pack, which this tool does not support")
+		case "XSIG":
+			return errors.New("signed filter, which this tool does not support")
+		case "XSET":
+			return errors.New("set written by Set.Save, which this tool does not support")
+		}
+	}
+	if _, xorErr := xorfilter.LoadXor8(bytes.NewReader(data)); xorErr == nil {
+		return errors.New("Xor8 filter, which this tool does not support")
+	}
+	if _, packedErr := xorfilter.LoadBinaryFusePacked(bytes.NewReader(data)); packedErr == nil {
+		return errors.New("bit-packed filter (BinaryFusePacked), which this tool does not support")
+	}
+	return err
+}
+
+func loadFilter[T xorfilter.Unsigned](data []byte, width int) (*filterFile, error) {
+	r := bytes.NewReader(data)
+	filter, err := xorfilter.LoadBinaryFuse[T](r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("unexpected data after the filter")
+	}
+	return &filterFile{
+		data:            data,
+		width:           width,
+		seed:            filter.Seed,
+		segmentLength:   filter.SegmentLength,
+		segmentCount:    filter.SegmentCount,
+		numFingerprints: len(filter.Fingerprints),
+		filter:          filter,
+	}, nil
+}
+
+func info(args []string, stdout io.Writer) error {
+	fs := newFlagSet("info")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	f, err := openFilter(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	maxKeys := f.filter.MaxKeys()
+	fmt.Fprintf(stdout, "seed: %#016x\n", f.seed)
+	fmt.Fprintf(stdout, "segment length: %d\n", f.segmentLength)
+	fmt.Fprintf(stdout, "segment count: %d\n", f.segmentCount)
+	fmt.Fprintf(stdout, "fingerprint bits: %d\n", f.width)
+	fmt.Fprintf(stdout, "fingerprints: %d\n", f.numFingerprints)
+	fmt.Fprintf(stdout, "size: %d bytes\n", len(f.data))
+	// The number of keys is not stored: report the bound implied by the
+	// number of fingerprints.
+	fmt.Fprintf(stdout, "max keys: %d\n", maxKeys)
+	if maxKeys > 0 {
+		fmt.Fprintf(stdout, "bits/key: >= %.2f\n", float64(len(f.data))*8/float64(maxKeys))
+	}
+	return nil
+}
+
+func query(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("query")
+	format, hasher := keyFlags(fs)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	parser, err := keyfile.NewParser(keyfile.Format(*format), *hasher)
+	if err != nil {
+		return err
+	}
+	f, err := openFilter(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := f.filter.Validate(); err != nil {
+		return err
+	}
+	return parser.Scan(stdin, func(line string, key uint64) error {
+		_, err := fmt.Fprintf(stdout, "%s\t%t\n", line, f.filter.Contains(key))
+		return err
+	})
+}
+
+func verify(args []string, stdout io.Writer) error {
+	fs := newFlagSet("verify")
+	checksum := fs.String("sha256", "", "expected SHA-256 of the file, in hex")
+	keysPath := fs.String("keys", "", "key file whose keys must all be in the filter")
+	format, hasher := keyFlags(fs)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	f, err := openFilter(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := f.filter.Validate(); err != nil {
+		return fmt.Errorf("invalid structure: %w", err)
+	}
+	fmt.Fprintln(stdout, "structure: ok")
+
+	sum := sha256.Sum256(f.data)
+	actual := hex.EncodeToString(sum[:])
+	if *checksum != "" {
+		if !strings.EqualFold(*checksum, actual) {
+			return fmt.Errorf("sha256 mismatch: got %s, expected %s", actual, *checksum)
+		}
+		fmt.Fprintf(stdout, "sha256: ok (%s)\n", actual)
+	} else {
+		fmt.Fprintf(stdout, "sha256: %s\n", actual)
+	}
+
+	if *keysPath != "" {
+		parser, err := keyfile.NewParser(keyfile.Format(*format), *hasher)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(*keysPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		count, missing := 0, 0
+		err = parser.Scan(file, func(_ string, key uint64) error {
+			count++
+			if !f.filter.Contains(key) {
+				missing++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if missing > 0 {
+			return fmt.Errorf("%d of %d keys are missing from the filter", missing, count)
+		}
+		fmt.Fprintf(stdout, "keys: ok (%d)\n", count)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/FastFilter/xorfilter/internal/keyfile"
+	"github.com/stretchr/testify/require"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), status
+}
+
+func TestBuildInfoQueryVerify(t *testing.T) {
+	dir := t.TempDir()
+	var keys strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&keys, "user-%d\n", i)
+	}
+	keysPath := filepath.Join(dir, "keys.txt")
+	require.NoError(t, os.WriteFile(keysPath, []byte(keys.String()), 0o644))
+
+	for _, width := range []string{"8", "16", "32"} {
+		path := filepath.Join(dir, "filter"+width+".bin")
+		out, stderr, status := runCommand(t, keys.String(), "build", "-format", "string", "-bits", width, "-out", path)
+		require.Equal(t, 0, status, stderr)
+		require.Contains(t, out, "keys: 10000\n")
+		var sum string
+		for _, line := range strings.Split(out, "\n") {
+			if s, ok := strings.CutPrefix(line, "sha256: "); ok {
+				sum = s
+			}
+		}
+		require.Len(t, sum, 64)
+
+		out, stderr, status = runCommand(t, "", "info", path)
+		require.Equal(t, 0, status, stderr)
+		require.Contains(t, out, "fingerprint bits: "+width+"\n")
+		require.Contains(t, out, "segment length: ")
+
+		out, stderr, status = runCommand(t, "user-1\nuser-9999\n", "query", "-format", "string", path)
+		require.Equal(t, 0, status, stderr)
+		require.Equal(t, "user-1\ttrue\nuser-9999\ttrue\n", out)
+
+		out, stderr, status = runCommand(t, "", "verify", "-sha256", sum, "-keys", keysPath, "-format", "string", path)
+		require.Equal(t, 0, status, stderr)
+		require.Contains(t, out, "structure: ok\n")
+		require.Contains(t, out, "keys: ok (10000)\n")
+
+		_, stderr, status = runCommand(t, "", "verify", "-sha256", strings.Repeat("0", 64), path)
+		require.Equal(t, 1, status)
+		require.Contains(t, stderr, "sha256 mismatch")
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "filter.bin")
+	_, stderr, status := runCommand(t, "1\n2\n3\n", "build", "-out", path)
+	require.Equal(t, 0, status, stderr)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// Corrupt the segment length mask.
+	data[12] ^= 1
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	_, stderr, status = runCommand(t, "", "verify", path)
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "invalid structure")
+
+	require.NoError(t, os.WriteFile(path, data[:30], 0o644))
+	_, _, status = runCommand(t, "", "info", path)
+	require.Equal(t, 1, status)
+}
+
+func TestUsage(t *testing.T) {
+	_, _, status := runCommand(t, "")
+	require.Equal(t, 2, status)
+	_, stderr, status := runCommand(t, "", "frobnicate")
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "unknown command")
+	_, _, status = runCommand(t, "", "build")
+	require.Equal(t, 2, status)
+	_, _, status = runCommand(t, "", "build", "-bits", "12", "-out", filepath.Join(t.TempDir(), "f"))
+	require.Equal(t, 2, status)
+}
+
+func TestCompressed(t *testing.T) {
+	parser, err := keyfile.NewParser(keyfile.String, "xxhash")
+	require.NoError(t, err)
+	var lines strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&lines, "user-%d\n", i)
+	}
+	keys, err := parser.ReadAll(strings.NewReader(lines.String()))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	save := func(name string, f interface{ SaveCompressed(io.Writer) error }) string {
+		var buf bytes.Buffer
+		require.NoError(t, f.SaveCompressed(&buf))
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+		return path
+	}
+	fuse8, err := xorfilter.NewBinaryFuse[uint8](keys)
+	require.NoError(t, err)
+	fuse16, err := xorfilter.NewBinaryFuse[uint16](keys)
+	require.NoError(t, err)
+	fuse32, err := xorfilter.NewBinaryFuse[uint32](keys)
+	require.NoError(t, err)
+	for width, path := range map[string]string{
+		"8":  save("fuse8.bin", fuse8),
+		"16": save("fuse16.bin", fuse16),
+		"32": save("fuse32.bin", fuse32),
+	} {
+		out, stderr, status := runCommand(t, "", "info", path)
+		require.Equal(t, 0, status, stderr)
+		require.Contains(t, out, "fingerprint bits: "+width+"\n")
+
+		out, stderr, status = runCommand(t, "user-1\nuser-9999\n", "query", "-format", "string", path)
+		require.Equal(t, 0, status, stderr)
+		require.Equal(t, "user-1\ttrue\nuser-9999\ttrue\n", out)
+	}
+
+	xor, err := xorfilter.Populate(keys)
+	require.NoError(t, err)
+	_, stderr, status := runCommand(t, "", "info", save("xor8.bin", xor))
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "compressed Xor8 filter")
+}
+
+func TestOtherFormats(t *testing.T) {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i)
+	}
+	dir := t.TempDir()
+	save := func(name string, f interface{ Save(io.Writer) error }) string {
+		var buf bytes.Buffer
+		require.NoError(t, f.Save(&buf))
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+		return path
+	}
+	xor, err := xorfilter.Populate(keys)
+	require.NoError(t, err)
+	packed, err := xorfilter.NewBinaryFusePacked(keys, 12)
+	require.NoError(t, err)
+	keyed, err := xorfilter.NewKeyedBinaryFuse[uint8](xorfilter.SecretKey{1}, keys)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, xorfilter.SaveFilter(&buf, xor))
+	typed := filepath.Join(dir, "typed.bin")
+	require.NoError(t, os.WriteFile(typed, buf.Bytes(), 0o644))
+
+	for path, expected := range map[string]string{
+		save("xor8.bin", xor):      "Xor8 filter",
+		save("packed.bin", packed): "bit-packed filter",
+		save("keyed.bin", keyed):   "keyed filter",
+		typed:                      "written by SaveFilter",
+	} {
+		_, stderr, status := runCommand(t, "", "info", path)
+		require.Equal(t, 1, status)
+		require.Contains(t, stderr, expected)
+	}
+}
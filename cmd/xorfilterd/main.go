@@ -0,0 +1,458 @@
+// Command xorfilterd serves the filters of a directory over the Redis
+// serialization protocol (RESP), so that clients of RedisBloom can query
+// immutable filters with BF.EXISTS, BF.MEXISTS and BF.INFO.
+//
+// Usage:
+//
+//	xorfilterd [-addr localhost:6379] [-format uint|hex|string] [-hash xxhash|fnv1a] -dir filters
+//
+// Each file of the directory is a filter, named after the file without its
+// extension:
+//
+//	name.xor8    an Xor8 written by Save or SaveCompressed
+//	name.fuse8   a BinaryFuse8 written by Save or SaveCompressed
+//	name.filter  a filter of any type written by SaveFilter
+//
+// Other files are ignored. Items are converted to keys like the lines of the
+// key files of the xorfilter command.
+//
+// The filters are read-only: BF.ADD and the other commands that modify a
+// filter fail. XF.RELOAD, or a SIGHUP signal, reads the directory again; if
+// a file fails to load, the previous filters are kept.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/FastFilter/xorfilter/internal/keyfile"
+)
+
+const usage = `usage:
+  xorfilterd [-addr localhost:6379] [-format uint|hex|string] [-hash xxhash|fnv1a] -dir filters
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run starts the server and returns the exit status when it stops.
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("xorfilterd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addr := fs.String("addr", "localhost:6379", "address to listen on")
+	dir := fs.String("dir", "", "directory of filter files")
+	format := fs.String("format", string(keyfile.Decimal), "item format: uint, hex or string")
+	hasher := fs.String("hash", "xxhash", "hash function for string items: xxhash or fnv1a")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *dir == "" {
+		if err == nil {
+			err = errors.New("missing -dir")
+		}
+		fmt.Fprintf(stderr, "xorfilterd: %v\n%s", err, usage)
+		return 2
+	}
+	logger := log.New(stderr, "xorfilterd: ", log.LstdFlags)
+	s, err := newServer(*dir, keyfile.Format(*format), *hasher, logger)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	logger.Printf("serving %d filters on %s", s.numFilters(), l.Addr())
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := s.reload(); err != nil {
+				logger.Printf("reload: %v", err)
+			}
+		}
+	}()
+	if err := s.serve(l); err != nil {
+		logger.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// filterExtensions maps the extensions of filter files to their loaders.
+var filterExtensions = map[string]func(io.Reader) (xorfilter.Filter, error){
+	".xor8": func(r io.Reader) (xorfilter.Filter, error) {
+		f, err := xorfilter.LoadXor8(r)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	},
+	".fuse8": func(r io.Reader) (xorfilter.Filter, error) {
+		f, err := xorfilter.LoadBinaryFuse8(r)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	},
+	".filter": xorfilter.LoadFilter,
+}
+
+// namedFilter is a loaded filter with the information reported by BF.INFO.
+type namedFilter struct {
+	filter xorfilter.Filter
+	kind   string
+	// size is the size of the filter file, in bytes.
+	size int64
+}
+
+type server struct {
+	dir     string
+	parser  *keyfile.Parser
+	logger  *log.Logger
+	filters atomic.Pointer[map[string]*namedFilter]
+
+	// reloadMu serializes reloads.
+	reloadMu sync.Mutex
+}
+
+// newServer creates a server and loads the filters of the directory.
+func newServer(dir string, format keyfile.Format, hasher string, logger *log.Logger) (*server, error) {
+	parser, err := keyfile.NewParser(format, hasher)
+	if err != nil {
+		return nil, err
+	}
+	s := &server{dir: dir, parser: parser, logger: logger}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *server) numFilters() int {
+	return len(*s.filters.Load())
+}
+
+// reload loads all the filters of the directory and publishes them, unless
+// one of them fails to load.
+func (s *server) reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	filters := make(map[string]*namedFilter)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		load := filterExtensions[ext]
+		if load == nil || entry.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if _, ok := filters[name]; ok {
+			return fmt.Errorf("several files for filter %q", name)
+		}
+		f, err := loadFile(filepath.Join(s.dir, entry.Name()), load)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		filters[name] = f
+	}
+	s.filters.Store(&filters)
+	return nil
+}
+
+func loadFile(path string, load func(io.Reader) (xorfilter.Filter, error)) (*namedFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	filter, err := load(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", filter), "*xorfilter.")
+	return &namedFilter{filter: filter, kind: kind, size: stat.Size()}, nil
+}
+
+// serve accepts connections until the listener fails or is closed.
+func (s *server) serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers the commands of a connection. Replies are flushed once
+// all the pipelined commands received so far have been answered.
+func (s *server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReaderSize(conn, 64<<10)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			var protocolErr protocolError
+			if errors.As(err, &protocolErr) {
+				writeError(w, "Protocol error: "+protocolErr.msg)
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(w, args)
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute writes the reply to a command, and reports whether the connection
+// must be closed.
+func (s *server) execute(w *bufio.Writer, args []string) (quit bool) {
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			writeBulk(w, args[1])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	case "COMMAND":
+		// Sent by redis-cli on startup.
+		writeArrayHeader(w, 0)
+	case "BF.EXISTS":
+		if len(args) != 3 {
+			writeArityError(w, name)
+			return false
+		}
+		found, err := s.contains(args[1], args[2])
+		if err != nil {
+			writeError(w, err.Error())
+			return false
+		}
+		writeBool(w, found)
+	case "BF.MEXISTS":
+		if len(args) < 3 {
+			writeArityError(w, name)
+			return false
+		}
+		results := make([]bool, len(args)-2)
+		for i, item := range args[2:] {
+			found, err := s.contains(args[1], item)
+			if err != nil {
+				writeError(w, err.Error())
+				return false
+			}
+			results[i] = found
+		}
+		writeArrayHeader(w, len(results))
+		for _, found := range results {
+			writeBool(w, found)
+		}
+	case "BF.INFO":
+		if len(args) != 2 && len(args) != 3 {
+			writeArityError(w, name)
+			return false
+		}
+		s.info(w, args[1:])
+	case "BF.ADD", "BF.MADD", "BF.INSERT", "BF.RESERVE", "BF.LOADCHUNK":
+		writeError(w, "filters are read-only")
+	case "XF.RELOAD":
+		if err := s.reload(); err != nil {
+			s.logger.Printf("reload: %v", err)
+			writeError(w, "reload failed: "+err.Error())
+			return false
+		}
+		writeSimple(w, "OK")
+	default:
+		writeError(w, fmt.Sprintf("unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// contains reports whether the item may be in the named filter. Like
+// RedisBloom, it reports false for a missing filter.
+func (s *server) contains(name, item string) (bool, error) {
+	f := (*s.filters.Load())[name]
+	if f == nil {
+		return false, nil
+	}
+	key, err := s.parser.Parse(item)
+	if err != nil {
+		return false, fmt.Errorf("invalid item: %v", err)
+	}
+	return f.filter.Contains(key), nil
+}
+
+// info answers BF.INFO, with all the fields or a single one. Size is the size
+// of the filter file.
+func (s *server) info(w *bufio.Writer, args []string) {
+	f := (*s.filters.Load())[args[0]]
+	if f == nil {
+		writeError(w, "not found")
+		return
+	}
+	if len(args) == 1 {
+		writeArrayHeader(w, 6)
+		writeSimple(w, "Type")
+		writeSimple(w, f.kind)
+		writeSimple(w, "Size")
+		writeInt(w, f.size)
+		writeSimple(w, "Number of filters")
+		writeInt(w, 1)
+		return
+	}
+	switch strings.ToUpper(args[1]) {
+	case "SIZE":
+		writeInt(w, f.size)
+	case "FILTERS":
+		writeInt(w, 1)
+	default:
+		writeError(w, "unsupported field "+args[1])
+	}
+}
+
+// Limits of the requests: number of arguments, bytes per argument and bytes
+// per command, where each argument also counts for the size of its string
+// header.
+const (
+	maxArgs        = 1 << 20
+	maxArgSize     = 1 << 20
+	maxCommandSize = 64 << 20
+	argOverhead    = 16
+)
+
+// protocolError is a malformed request. The connection is closed after
+// reporting it.
+type protocolError struct{ msg string }
+
+func (e protocolError) Error() string { return e.msg }
+
+// readCommand reads a command, either as an array of bulk strings or as an
+// inline command separated by spaces.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, protocolError{"invalid multibulk length"}
+	}
+	args := make([]string, 0, min(max(n, 0), 64))
+	total := 0
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, protocolError{fmt.Sprintf("expected '$', got '%.1s'", line)}
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxArgSize {
+			return nil, protocolError{"invalid bulk length"}
+		}
+		// The budget is checked before the argument is allocated.
+		if total += size + argOverhead; total > maxCommandSize {
+			return nil, protocolError{"command too large"}
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, protocolError{"missing CRLF after bulk string"}
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by "\r\n" or "\n", without the
+// terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", protocolError{"line too long"}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	// Simple strings cannot contain line breaks.
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	w.WriteString("-ERR " + msg + "\r\n")
+}
+
+func writeArityError(w *bufio.Writer, name string) {
+	writeError(w, fmt.Sprintf("wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBool(w *bufio.Writer, b bool) {
+	if b {
+		writeInt(w, 1)
+	} else {
+		writeInt(w, 0)
+	}
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeArrayHeader(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
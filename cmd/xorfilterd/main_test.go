@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// saveFile writes a filter file with the given save function.
+func saveFile(t *testing.T, path string, save func(io.Writer) error) {
+	var buf bytes.Buffer
+	require.NoError(t, save(&buf))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func stringKeys(prefix string, n int) []uint64 {
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = xxhash.Sum64String(fmt.Sprintf("%s%d", prefix, i))
+	}
+	return keys
+}
+
+// client sends commands and reads replies, decoded as strings, integers,
+// errors and slices of replies.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *client) do(args ...string) any {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := c.conn.Write(buf.Bytes())
+	require.NoError(c.t, err)
+	return c.read()
+}
+
+type replyError string
+
+func (c *client) read() any {
+	line, err := c.r.ReadString('\n')
+	require.NoError(c.t, err)
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return replyError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		require.NoError(c.t, err)
+		return n
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(c.t, err)
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, buf)
+		require.NoError(c.t, err)
+		return string(buf[:n])
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(c.t, err)
+		values := make([]any, n)
+		for i := range values {
+			values[i] = c.read()
+		}
+		return values
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+func startServer(t *testing.T, dir string) *client {
+	s, err := newServer(dir, "string", "xxhash", log.New(io.Discard, "", 0))
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go s.serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	xor, err := xorfilter.Populate(stringKeys("xor-", 1000))
+	require.NoError(t, err)
+	saveFile(t, filepath.Join(dir, "users.xor8"), xor.Save)
+	fuse, err := xorfilter.PopulateBinaryFuse8(stringKeys("fuse-", 1000))
+	require.NoError(t, err)
+	saveFile(t, filepath.Join(dir, "items.fuse8"), fuse.SaveCompressed)
+	fuse16, err := xorfilter.NewBinaryFuse[uint16](stringKeys("wide-", 1000))
+	require.NoError(t, err)
+	saveFile(t, filepath.Join(dir, "wide.filter"), func(w io.Writer) error { return xorfilter.SaveFilter(w, fuse16) })
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0o644))
+
+	c := startServer(t, dir)
+	require.Equal(t, "PONG", c.do("PING"))
+	require.Equal(t, []any{}, c.do("COMMAND", "DOCS"))
+	require.Equal(t, int64(1), c.do("BF.EXISTS", "users", "xor-7"))
+	require.Equal(t, int64(1), c.do("bf.exists", "items", "fuse-999"))
+	require.Equal(t, int64(1), c.do("BF.EXISTS", "wide", "wide-0"))
+	require.Equal(t, int64(0), c.do("BF.EXISTS", "wide", "fuse-0"))
+	require.Equal(t, int64(0), c.do("BF.EXISTS", "missing", "xor-7"))
+	require.Equal(t, []any{int64(1), int64(0), int64(1)}, c.do("BF.MEXISTS", "users", "xor-1", "wide-1", "xor-2"))
+
+	stat, err := os.Stat(filepath.Join(dir, "items.fuse8"))
+	require.NoError(t, err)
+	require.Equal(t, []any{"Type", "BinaryFuse8", "Size", stat.Size(), "Number of filters", int64(1)}, c.do("BF.INFO", "items"))
+	require.Equal(t, stat.Size(), c.do("BF.INFO", "items", "size"))
+	info := c.do("BF.INFO", "wide").([]any)
+	require.Equal(t, "BinaryFuse[uint16]", info[1])
+
+	require.IsType(t, replyError(""), c.do("BF.INFO", "missing"))
+	require.IsType(t, replyError(""), c.do("BF.ADD", "users", "x"))
+	require.IsType(t, replyError(""), c.do("BF.EXISTS", "users"))
+	require.IsType(t, replyError(""), c.do("GET", "users"))
+
+	// Reloading picks up new files, and keeps the previous filters if one
+	// of them is invalid.
+	extra, err := xorfilter.PopulateBinaryFuse8(stringKeys("extra-", 100))
+	require.NoError(t, err)
+	saveFile(t, filepath.Join(dir, "extra.fuse8"), extra.Save)
+	require.Equal(t, int64(0), c.do("BF.EXISTS", "extra", "extra-1"))
+	require.Equal(t, "OK", c.do("XF.RELOAD"))
+	require.Equal(t, int64(1), c.do("BF.EXISTS", "extra", "extra-1"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users.xor8"), []byte("corrupt"), 0o644))
+	require.IsType(t, replyError(""), c.do("XF.RELOAD"))
+	require.Equal(t, int64(1), c.do("BF.EXISTS", "users", "xor-7"))
+
+	// Inline and pipelined commands.
+	_, err = c.conn.Write([]byte("PING\r\nBF.EXISTS extra extra-2\nPING hello\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, "PONG", c.read())
+	require.Equal(t, int64(1), c.read())
+	require.Equal(t, "hello", c.read())
+
+	require.Equal(t, "OK", c.do("QUIT"))
+	_, err = c.r.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestProtocolError(t *testing.T) {
+	c := startServer(t, t.TempDir())
+	_, err := c.conn.Write([]byte("*1\r\n+PING\r\n"))
+	require.NoError(t, err)
+	reply := c.read()
+	require.IsType(t, replyError(""), reply)
+	require.Contains(t, reply, "Protocol error")
+	_, err = c.r.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	require.Equal(t, 2, run(nil, &stderr))
+	require.Contains(t, stderr.String(), "missing -dir")
+	stderr.Reset()
+	require.Equal(t, 1, run([]string{"-dir", filepath.Join(t.TempDir(), "missing")}, &stderr))
+	stderr.Reset()
+	require.Equal(t, 1, run([]string{"-dir", t.TempDir(), "-format", "bogus"}, &stderr))
+}
+
+// repeatReader returns a multibulk header followed by the same bulk string
+// again and again, without holding the command in memory.
+type repeatReader struct {
+	header, arg []byte
+	off         int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if len(r.header) > 0 {
+		n := copy(p, r.header)
+		r.header = r.header[n:]
+		return n, nil
+	}
+	n := copy(p, r.arg[r.off:])
+	r.off = (r.off + n) % len(r.arg)
+	return n, nil
+}
+
+func TestCommandTooLarge(t *testing.T) {
+	arg := append([]byte(fmt.Sprintf("$%d\r\n", maxArgSize)), bytes.Repeat([]byte("a"), maxArgSize)...)
+	arg = append(arg, "\r\n"...)
+	r := &repeatReader{header: []byte(fmt.Sprintf("*%d\r\n", maxArgs)), arg: arg}
+	_, err := readCommand(bufio.NewReader(r))
+	require.ErrorContains(t, err, "command too large")
+}
@@ -0,0 +1,340 @@
+package xorfilter
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sync"
+)
+
+// DynamicFilterOptions configures a DynamicFilter. The zero value selects
+// the defaults.
+type DynamicFilterOptions struct {
+	// BufferSize is the number of keys kept in the exact buffer before they
+	// are frozen into a BinaryFuse8 segment. Defaults to 4096.
+	BufferSize int
+	// Fanout is the number of segments a level may hold; when a level reaches
+	// it, its segments are merged into one segment of the next level.
+	// Defaults to 4.
+	Fanout int
+	// MaxSegments bounds the number of segments, sealed or not, and thus the
+	// false positive probability, which is at most MaxSegments times that of
+	// a BinaryFuse8. When it is exceeded, the smallest mergeable segments are
+	// merged. Defaults to 8.
+	MaxSegments int
+}
+
+// DynamicFilter is an append-only approximate set built as a log-structured
+// stack of immutable BinaryFuse8 filters. Recent keys are kept in a small
+// exact buffer which is periodically frozen into a segment; segments are then
+// merged level by level, like in a log-structured merge tree.
+//
+// Since binary fuse filters cannot be merged directly, the filter retains the
+// keys of each segment in addition to the fingerprints: a mergeable segment
+// costs about 73 bits per key (64 bits for the key and 9 bits for the
+// fingerprints). Seal drops the keys, leaving about 9 bits per key, at the
+// cost of a segment that is never merged again.
+//
+// A DynamicFilter is safe for concurrent use.
+type DynamicFilter struct {
+	mu          sync.RWMutex
+	bufferSize  int
+	fanout      int
+	maxSegments int
+	buffer      map[uint64]struct{}
+	// levels[i] holds the mergeable segments of level i, oldest first.
+	levels [][]dynamicSegment
+	// sealed holds the segments whose keys were dropped by Seal.
+	sealed     []*BinaryFuse8
+	sealedKeys int
+	builder    BinaryFuseBuilder
+}
+
+type dynamicSegment struct {
+	filter *BinaryFuse8
+	// keys are the sorted, deduplicated keys of the segment.
+	keys []uint64
+}
+
+// NewDynamicFilter creates an empty DynamicFilter. The filter retains the
+// 64-bit keys of its mergeable segments, so until Seal is called it uses
+// about 73 bits per key: when the keys are known in advance, a BinaryFuse8
+// built from them takes 9.
+func NewDynamicFilter(opts DynamicFilterOptions) *DynamicFilter {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 4096
+	}
+	if opts.Fanout < 2 {
+		opts.Fanout = 4
+	}
+	if opts.MaxSegments <= 0 {
+		opts.MaxSegments = 8
+	}
+	return &DynamicFilter{
+		bufferSize:  opts.BufferSize,
+		fanout:      opts.Fanout,
+		maxSegments: opts.MaxSegments,
+		buffer:      make(map[uint64]struct{}, opts.BufferSize),
+	}
+}
+
+// Add inserts a key. It returns an error if a segment could not be built, in
+// which case the key is still in the filter.
+func (d *DynamicFilter) Add(key uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buffer[key] = struct{}{}
+	if len(d.buffer) < d.bufferSize {
+		return nil
+	}
+	return d.flush()
+}
+
+// AddAll inserts all the keys.
+func (d *DynamicFilter) AddAll(keys []uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range keys {
+		d.buffer[key] = struct{}{}
+		if len(d.buffer) >= d.bufferSize {
+			if err := d.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush freezes the buffered keys into a segment.
+func (d *DynamicFilter) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.flush()
+}
+
+// Compact merges all the keys, including the buffered ones, into a single
+// segment, which minimizes the false positive probability.
+func (d *DynamicFilter) Compact() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.flush(); err != nil {
+		return err
+	}
+	var all []dynamicSegment
+	for _, level := range d.levels {
+		all = append(all, level...)
+	}
+	if len(all) <= 1 {
+		return nil
+	}
+	merged, err := d.merge(all)
+	if err != nil {
+		return err
+	}
+	d.levels = nil
+	d.place(merged)
+	return nil
+}
+
+// ErrTooManySegments is returned by Seal when the sealed segments would leave
+// no room for a mergeable segment under MaxSegments.
+var ErrTooManySegments = errors.New("too many sealed segments")
+
+// Seal compacts all the mergeable keys, including the buffered ones, into a
+// single segment and drops its keys, so that it only costs about 9 bits per
+// key. A sealed segment is never merged again but counts toward MaxSegments,
+// which leaves fewer segments for the keys added later: Seal fails with
+// ErrTooManySegments rather than seal MaxSegments segments.
+func (d *DynamicFilter) Seal() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.sealed)+1 >= d.maxSegments {
+		return ErrTooManySegments
+	}
+	if err := d.flush(); err != nil {
+		return err
+	}
+	var all []dynamicSegment
+	for _, level := range d.levels {
+		all = append(all, level...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	merged := all[0]
+	if len(all) > 1 {
+		var err error
+		if merged, err = d.merge(all); err != nil {
+			return err
+		}
+	}
+	d.levels = nil
+	d.sealed = append(d.sealed, merged.filter)
+	d.sealedKeys += len(merged.keys)
+	return nil
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability bounded by FalsePositiveRate.
+func (d *DynamicFilter) Contains(key uint64) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.buffer[key]; ok {
+		return true
+	}
+	for _, filter := range d.sealed {
+		if filter.Contains(key) {
+			return true
+		}
+	}
+	for _, level := range d.levels {
+		for _, s := range level {
+			if s.filter.Contains(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Len returns the number of distinct keys per segment plus the number of
+// buffered keys. A key added again after it was frozen is counted twice
+// until the segments holding it are merged, and keys sealed more than once
+// are counted once per sealed segment.
+func (d *DynamicFilter) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	n := len(d.buffer) + d.sealedKeys
+	for _, level := range d.levels {
+		for _, s := range level {
+			n += len(s.keys)
+		}
+	}
+	return n
+}
+
+// Segments returns the number of frozen segments, sealed or not.
+func (d *DynamicFilter) Segments() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.segments()
+}
+
+// FalsePositiveRate returns an upper bound on the current false positive
+// probability, assuming each segment has the 2^-8 false positive probability
+// of a BinaryFuse8. Since the number of segments is bounded by MaxSegments,
+// so is the result.
+func (d *DynamicFilter) FalsePositiveRate() float64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return 1 - math.Pow(1-1.0/256, float64(d.segments()))
+}
+
+func (d *DynamicFilter) segments() int {
+	return len(d.sealed) + d.mergeableSegments()
+}
+
+func (d *DynamicFilter) mergeableSegments() int {
+	n := 0
+	for _, level := range d.levels {
+		n += len(level)
+	}
+	return n
+}
+
+func (d *DynamicFilter) flush() error {
+	if len(d.buffer) == 0 {
+		return nil
+	}
+	keys := make([]uint64, 0, len(d.buffer))
+	for key := range d.buffer {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	s, err := d.build(keys)
+	if err != nil {
+		return err
+	}
+	clear(d.buffer)
+	d.place(s)
+	return d.enforceMaxSegments()
+}
+
+// place adds the segment to the level matching its size and merges full
+// levels into the next one.
+func (d *DynamicFilter) place(s dynamicSegment) {
+	level := 0
+	for limit := d.bufferSize; len(s.keys) > limit; limit *= d.fanout {
+		level++
+	}
+	for len(d.levels) <= level {
+		d.levels = append(d.levels, nil)
+	}
+	d.levels[level] = append(d.levels[level], s)
+	for i := level; i < len(d.levels); i++ {
+		if len(d.levels[i]) < d.fanout {
+			break
+		}
+		merged, err := d.merge(d.levels[i])
+		if err != nil {
+			// Keep the level as it is; the segments remain queryable and the
+			// merge is retried on the next insertion into this level.
+			break
+		}
+		d.levels[i] = nil
+		if i+1 == len(d.levels) {
+			d.levels = append(d.levels, nil)
+		}
+		d.levels[i+1] = append(d.levels[i+1], merged)
+	}
+}
+
+// enforceMaxSegments merges the smallest mergeable segments until there are
+// at most maxSegments segments, sealed or not. Seal leaves room for at least
+// one mergeable segment.
+func (d *DynamicFilter) enforceMaxSegments() error {
+	excess := d.segments() - d.maxSegments
+	if excess <= 0 {
+		return nil
+	}
+	var smallest []dynamicSegment
+	for i := range d.levels {
+		for len(d.levels[i]) > 0 && len(smallest) <= excess {
+			smallest = append(smallest, d.levels[i][0])
+			d.levels[i] = d.levels[i][1:]
+		}
+	}
+	merged, err := d.merge(smallest)
+	if err != nil {
+		for _, s := range smallest {
+			d.place(s)
+		}
+		return err
+	}
+	d.place(merged)
+	return nil
+}
+
+func (d *DynamicFilter) merge(segments []dynamicSegment) (dynamicSegment, error) {
+	n := 0
+	for _, s := range segments {
+		n += len(s.keys)
+	}
+	keys := make([]uint64, 0, n)
+	for _, s := range segments {
+		keys = append(keys, s.keys...)
+	}
+	if len(keys) > 0 {
+		keys = pruneDuplicates(keys)
+	}
+	return d.build(keys)
+}
+
+// build creates a segment from sorted, deduplicated keys.
+func (d *DynamicFilter) build(keys []uint64) (dynamicSegment, error) {
+	filter, err := buildOwned[uint8](&d.builder, keys)
+	if err != nil {
+		return dynamicSegment{}, err
+	}
+	return dynamicSegment{filter: (*BinaryFuse8)(&filter), keys: keys}, nil
+}
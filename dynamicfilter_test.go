@@ -0,0 +1,128 @@
+package xorfilter
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicFilter(t *testing.T) {
+	d := NewDynamicFilter(DynamicFilterOptions{BufferSize: 1000, Fanout: 4, MaxSegments: 6})
+	keys := make([]uint64, 200_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+		require.NoError(t, d.Add(keys[i]))
+		if i%10_000 == 0 {
+			require.LessOrEqual(t, d.Segments(), 6)
+		}
+	}
+	for _, v := range keys {
+		require.True(t, d.Contains(v))
+	}
+	require.Equal(t, len(keys), d.Len())
+	require.LessOrEqual(t, d.Segments(), 6)
+
+	falsesize := 1_000_000
+	matches := 0
+	for i := 0; i < falsesize; i++ {
+		if d.Contains(rand.Uint64()) {
+			matches++
+		}
+	}
+	fpp := float64(matches) / float64(falsesize)
+	expected := d.FalsePositiveRate()
+	// The number of matches is binomial; allow 5 standard deviations.
+	sigma := math.Sqrt(expected * (1 - expected) / float64(falsesize))
+	t.Logf("segments %d false positive rate %.4f (expected %.4f)", d.Segments(), fpp, expected)
+	assert.InDelta(t, expected, fpp, 5*sigma)
+
+	require.NoError(t, d.Compact())
+	require.Equal(t, 1, d.Segments())
+	for _, v := range keys {
+		require.True(t, d.Contains(v))
+	}
+}
+
+func TestDynamicFilterDuplicates(t *testing.T) {
+	d := NewDynamicFilter(DynamicFilterOptions{BufferSize: 10})
+	for i := 0; i < 100; i++ {
+		require.NoError(t, d.AddAll([]uint64{1, 2, 3, uint64(i)}))
+	}
+	require.NoError(t, d.Compact())
+	require.Equal(t, 100, d.Len())
+	for i := 0; i < 100; i++ {
+		require.True(t, d.Contains(uint64(i)))
+	}
+}
+
+func TestDynamicFilterConcurrent(t *testing.T) {
+	d := NewDynamicFilter(DynamicFilterOptions{BufferSize: 100})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := uint64(w)<<32 | uint64(i)
+				if err := d.Add(key); err != nil {
+					t.Error(err)
+					return
+				}
+				if !d.Contains(key) {
+					t.Errorf("key %d not found", key)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	require.Equal(t, 8000, d.Len())
+}
+
+func TestDynamicFilterSeal(t *testing.T) {
+	d := NewDynamicFilter(DynamicFilterOptions{BufferSize: 1000})
+	keys := make([]uint64, 10_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	require.NoError(t, d.AddAll(keys[:5000]))
+	require.NoError(t, d.Seal())
+	require.Equal(t, 1, d.Segments())
+	require.Empty(t, d.levels)
+	require.NoError(t, d.AddAll(keys[5000:]))
+	require.NoError(t, d.Compact())
+	require.Equal(t, 2, d.Segments())
+	require.Equal(t, len(keys), d.Len())
+	for _, v := range keys {
+		require.True(t, d.Contains(v))
+	}
+}
+
+func TestDynamicFilterSealBounded(t *testing.T) {
+	d := NewDynamicFilter(DynamicFilterOptions{BufferSize: 100, MaxSegments: 4})
+	var keys []uint64
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 1000; j++ {
+			key := rand.Uint64()
+			keys = append(keys, key)
+			require.NoError(t, d.Add(key))
+			require.LessOrEqual(t, d.Segments(), 4)
+		}
+		err := d.Seal()
+		if i < 3 {
+			require.NoError(t, err)
+		} else {
+			require.ErrorIs(t, err, ErrTooManySegments)
+		}
+		require.LessOrEqual(t, d.Segments(), 4)
+	}
+	require.Len(t, d.sealed, 3)
+	require.LessOrEqual(t, d.FalsePositiveRate(), 4.0/256)
+	for _, v := range keys {
+		require.True(t, d.Contains(v))
+	}
+}
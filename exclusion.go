@@ -0,0 +1,137 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// ExclusionOptions configures NewBinaryFuseExcluding.
+type ExclusionOptions struct {
+	// SeedAttempts is the number of seeds tried, keeping the one that accepts
+	// the fewest negatives. Defaults to 16.
+	SeedAttempts int
+}
+
+// BinaryFuseExcluding is a binary fuse filter that never accepts a given set
+// of known non-member keys, such as the most frequent misses of a cache.
+// Among several seeds, the one accepting the fewest of these negatives is
+// kept, and the negatives it still accepts are stored in an exact list of
+// exceptions.
+//
+// About one negative in 2^bits is accepted by a given seed, so the seed
+// search alone suffices for up to a few times 2^bits negatives (a few
+// hundred with 8-bit fingerprints); beyond that, the exceptions grow with
+// the number of negatives and cost 64 bits each.
+type BinaryFuseExcluding[T Unsigned] struct {
+	BinaryFuse[T]
+
+	// Exceptions are the sorted negatives that BinaryFuse[T] accepts.
+	Exceptions []uint64
+}
+
+// NewBinaryFuseExcluding creates a filter over keys that rejects all the
+// negatives. Negatives that are also keys are ignored: they remain members.
+// The number of negatives that needed the exception list is
+// len(filter.Exceptions). Neither slice is modified.
+func NewBinaryFuseExcluding[T Unsigned](keys, negatives []uint64, opts ExclusionOptions) (*BinaryFuseExcluding[T], error) {
+	if opts.SeedAttempts <= 0 {
+		opts.SeedAttempts = 16
+	}
+	keys = sortedKeys(keys)
+	var candidates []uint64
+	for _, key := range sortedKeys(negatives) {
+		if _, found := slices.BinarySearch(keys, key); !found {
+			candidates = append(candidates, key)
+		}
+	}
+
+	var b BinaryFuseBuilder
+	var best *BinaryFuseExcluding[T]
+	tried := make(map[uint64]bool)
+	// The seeds come from a sequence distinct from the one of buildBinaryFuse,
+	// which is used when a seed cannot peel the keys.
+	rngcounter := uint64(0x5851f42d4c957f2d)
+	for attempt := 0; attempt < opts.SeedAttempts; attempt++ {
+		seed := splitmix64(&rngcounter)
+		filter, err := buildOwnedSeeded[T](&b, keys, &seed)
+		if err != nil {
+			return nil, err
+		}
+		if tried[filter.Seed] {
+			continue
+		}
+		tried[filter.Seed] = true
+		var exceptions []uint64
+		for _, key := range candidates {
+			if filter.Contains(key) {
+				exceptions = append(exceptions, key)
+				if best != nil && len(exceptions) >= len(best.Exceptions) {
+					break
+				}
+			}
+		}
+		if best == nil || len(exceptions) < len(best.Exceptions) {
+			best = &BinaryFuseExcluding[T]{BinaryFuse: filter, Exceptions: exceptions}
+		}
+		if len(best.Exceptions) == 0 {
+			break
+		}
+	}
+	return best, nil
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability, and `false` for all the negatives of the filter.
+func (f *BinaryFuseExcluding[T]) Contains(key uint64) bool {
+	if !f.BinaryFuse.Contains(key) {
+		return false
+	}
+	_, found := slices.BinarySearch(f.Exceptions, key)
+	return !found
+}
+
+// Save writes the filter in the format of BinaryFuse[T].Save, followed by the
+// number of exceptions as a uint64 and the sorted exceptions, in little
+// endian format.
+func (f *BinaryFuseExcluding[T]) Save(w io.Writer) error {
+	if err := f.BinaryFuse.Save(w); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(f.Exceptions))); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, f.Exceptions)
+}
+
+// LoadBinaryFuseExcluding reads the filter from the reader in little endian
+// format.
+func LoadBinaryFuseExcluding[T Unsigned](r io.Reader) (*BinaryFuseExcluding[T], error) {
+	return LoadBinaryFuseExcludingWithOptions[T](r, LoadOptions{})
+}
+
+// LoadBinaryFuseExcludingWithOptions is LoadBinaryFuseExcluding with
+// restrictions on the filters it accepts.
+func LoadBinaryFuseExcludingWithOptions[T Unsigned](r io.Reader, opts LoadOptions) (*BinaryFuseExcluding[T], error) {
+	budget := opts.budget()
+	filter, err := loadBinaryFuse[T](r, budget)
+	if err != nil {
+		return nil, err
+	}
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if err := spend[uint64](budget, n, "exceptions"); err != nil {
+		return nil, err
+	}
+	exceptions, err := readSlice[uint64](r, n)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.IsSorted(exceptions) {
+		return nil, errors.New("exceptions are not sorted")
+	}
+	return &BinaryFuseExcluding[T]{BinaryFuse: *filter, Exceptions: exceptions}, nil
+}
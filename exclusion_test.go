@@ -0,0 +1,53 @@
+package xorfilter
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryFuseExcluding(t *testing.T) {
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	for _, numNegatives := range []int{100, 100000} {
+		negatives := make([]uint64, numNegatives)
+		for i := range negatives {
+			negatives[i] = rand.Uint64()
+		}
+		// A negative that is also a key remains a member.
+		negatives = append(negatives, keys[0])
+		input := slices.Clone(negatives)
+
+		filter, err := NewBinaryFuseExcluding[uint8](keys, negatives, ExclusionOptions{})
+		require.NoError(t, err)
+		require.Equal(t, input, negatives)
+		t.Logf("%d negatives: %d exceptions", numNegatives, len(filter.Exceptions))
+		if numNegatives == 100 {
+			require.Empty(t, filter.Exceptions)
+		} else {
+			// A single seed accepts about 1/256 of the negatives.
+			require.Less(t, len(filter.Exceptions), numNegatives/256)
+		}
+		for _, key := range keys {
+			require.True(t, filter.Contains(key))
+		}
+		for _, key := range negatives[:numNegatives] {
+			require.False(t, filter.Contains(key))
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, filter.Save(&buf))
+		loaded, err := LoadBinaryFuseExcluding[uint8](&buf)
+		require.NoError(t, err)
+		require.Equal(t, filter.BinaryFuse, loaded.BinaryFuse)
+		require.Equal(t, len(filter.Exceptions), len(loaded.Exceptions))
+		for _, key := range negatives[:numNegatives] {
+			require.False(t, loaded.Contains(key))
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Filter is the interface shared by the immutable filters of this package:
+// *Xor8, *BinaryFuse8, *BinaryFuse[T] and *BinaryFusePacked.
+type Filter interface {
+	// Contains returns `true` if key is part of the set with a false positive
+	// probability.
+	Contains(key uint64) bool
+	// Save writes the filter to the writer, without its type.
+	Save(w io.Writer) error
+}
+
+// NewFilterForFPR creates the filter with the smallest memory usage whose
+// false positive probability does not exceed targetFPR, among Xor8 and
+// binary fuse filters with 1 to 32-bit fingerprints. A b-bit fingerprint
+// gives a false positive probability of 2^-b, so the width is the smallest b
+// with 2^-b <= targetFPR. Widths of 8, 16 and 32 bits use BinaryFuse[T],
+// which has faster queries than BinaryFusePacked for the same size; with 8
+// bits, an Xor8 is chosen when it is smaller, which happens for small sets.
+//
+// Use SaveFilter to record the chosen type along with the filter.
+//
+// The keys slice is not modified.
+func NewFilterForFPR(keys []uint64, targetFPR float64) (Filter, error) {
+	if !(targetFPR > 0) || targetFPR >= 1 {
+		return nil, errors.New("the target false positive rate must be in (0, 1)")
+	}
+	width := int(math.Ceil(-math.Log2(targetFPR)))
+	if width > 32 {
+		return nil, fmt.Errorf("no filter reaches a false positive rate of %g", targetFPR)
+	}
+	width = max(width, 1)
+	switch width {
+	case 8:
+		if len(keys) > 0 && xor8Size(len(keys)) < fuseSize(len(keys), 8) {
+			return asFilter(Populate(keys))
+		}
+		return asFilter(NewBinaryFuse[uint8](keys))
+	case 16:
+		return asFilter(NewBinaryFuse[uint16](keys))
+	case 32:
+		return asFilter(NewBinaryFuse[uint32](keys))
+	}
+	return asFilter(NewBinaryFusePacked(keys, width))
+}
+
+// asFilter converts the result of a constructor, so that a failure gives a
+// nil Filter rather than a Filter holding a nil pointer.
+func asFilter[F Filter](f F, err error) (Filter, error) {
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// xor8Size returns the number of bytes of the fingerprints of an Xor8 over
+// size keys.
+func xor8Size(size int) uint64 {
+	capacity := 32 + uint64(math.Ceil(1.23*float64(size)))
+	return capacity / 3 * 3
+}
+
+// fuseSize returns the number of bytes of the fingerprints of a binary fuse
+// filter over size keys.
+func fuseSize(size int, width uint32) uint64 {
+	var f BinaryFuse[uint8]
+	return uint64(f.setParameters(uint32(size))) * uint64(width) / 8
+}
+
+// filterMagic starts the output of SaveFilter.
+var filterMagic = [4]byte{'X', 'F', 'L', 'T'}
+
+// FilterType identifies the concrete type of a Filter, in the output of
+// SaveFilter and in filter packs.
+type FilterType uint32
+
+const (
+	TypeXor8 FilterType = iota + 1
+	TypeBinaryFuse8
+	TypeBinaryFuse16
+	TypeBinaryFuse32
+	TypeBinaryFusePacked
+)
+
+func (t FilterType) String() string {
+	switch t {
+	case TypeXor8:
+		return "xor8"
+	case TypeBinaryFuse8:
+		return "binaryfuse8"
+	case TypeBinaryFuse16:
+		return "binaryfuse16"
+	case TypeBinaryFuse32:
+		return "binaryfuse32"
+	case TypeBinaryFusePacked:
+		return "binaryfusepacked"
+	}
+	return fmt.Sprintf("FilterType(%d)", uint32(t))
+}
+
+// FilterTypeOf returns the type of the filter. A *BinaryFuse8 has the type
+// of a *BinaryFuse[uint8], since both are saved in the same format.
+func FilterTypeOf(f Filter) (FilterType, error) {
+	switch f.(type) {
+	case *Xor8:
+		return TypeXor8, nil
+	case *BinaryFuse8, *BinaryFuse[uint8]:
+		return TypeBinaryFuse8, nil
+	case *BinaryFuse[uint16]:
+		return TypeBinaryFuse16, nil
+	case *BinaryFuse[uint32]:
+		return TypeBinaryFuse32, nil
+	case *BinaryFusePacked:
+		return TypeBinaryFusePacked, nil
+	}
+	return 0, fmt.Errorf("unsupported filter type %T", f)
+}
+
+// SaveFilter writes a magic number, the type of the filter as a uint32 and
+// the filter as written by its Save method, so that LoadFilter can restore
+// it without knowing its type.
+func SaveFilter(w io.Writer, f Filter) error {
+	t, err := FilterTypeOf(f)
+	if err != nil {
+		return err
+	}
+	var header [8]byte
+	copy(header[:], filterMagic[:])
+	binary.LittleEndian.PutUint32(header[4:], uint32(t))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	return f.Save(w)
+}
+
+// LoadFilter reads a filter written by SaveFilter. The dynamic type of the
+// result is that of the saved filter, except that a *BinaryFuse8 is loaded as
+// a *BinaryFuse[uint8].
+func LoadFilter(r io.Reader) (Filter, error) {
+	return LoadFilterWithOptions(r, LoadOptions{})
+}
+
+// LoadFilterWithOptions is LoadFilter with restrictions on the filters it
+// accepts.
+func LoadFilterWithOptions(r io.Reader, opts LoadOptions) (Filter, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte(header[:4]) != filterMagic {
+		return nil, errors.New("not a filter saved by SaveFilter")
+	}
+	switch FilterType(binary.LittleEndian.Uint32(header[4:])) {
+	case TypeXor8:
+		return asFilter(LoadXor8WithOptions(r, opts))
+	case TypeBinaryFuse8:
+		return asFilter(LoadBinaryFuseWithOptions[uint8](r, opts))
+	case TypeBinaryFuse16:
+		return asFilter(LoadBinaryFuseWithOptions[uint16](r, opts))
+	case TypeBinaryFuse32:
+		return asFilter(LoadBinaryFuseWithOptions[uint32](r, opts))
+	case TypeBinaryFusePacked:
+		return asFilter(LoadBinaryFusePackedWithOptions(r, opts))
+	}
+	return nil, errors.New("unknown filter type")
+}
@@ -0,0 +1,88 @@
+package xorfilter
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFilterForFPR(t *testing.T) {
+	large := make([]uint64, 100000)
+	for i := range large {
+		large[i] = rand.Uint64()
+	}
+	small := large[:50]
+	for _, tc := range []struct {
+		keys      []uint64
+		targetFPR float64
+		expected  Filter
+		bits      uint32
+	}{
+		{large, 0.5, &BinaryFusePacked{}, 1},
+		{large, 0.01, &BinaryFusePacked{}, 7},
+		{large, 1.0 / 256, &BinaryFuse[uint8]{}, 0},
+		{small, 0.004, &Xor8{}, 0},
+		{large, 0.001, &BinaryFusePacked{}, 10},
+		{large, 1.0 / (1 << 16), &BinaryFuse[uint16]{}, 0},
+		{large, 1e-6, &BinaryFusePacked{}, 20},
+		{large, 1.0 / (1 << 32), &BinaryFuse[uint32]{}, 0},
+	} {
+		filter, err := NewFilterForFPR(append([]uint64(nil), tc.keys...), tc.targetFPR)
+		require.NoError(t, err)
+		require.IsType(t, tc.expected, filter, "target %g", tc.targetFPR)
+		if packed, ok := filter.(*BinaryFusePacked); ok {
+			require.Equal(t, tc.bits, packed.Bits)
+		}
+		for _, key := range tc.keys {
+			require.True(t, filter.Contains(key))
+		}
+		if tc.targetFPR >= 0.001 {
+			matches := 0
+			for range 100000 {
+				if filter.Contains(rand.Uint64()) {
+					matches++
+				}
+			}
+			// The number of matches is binomial; allow 5 standard deviations.
+			sigma := math.Sqrt(tc.targetFPR * (1 - tc.targetFPR) / 100000)
+			require.Less(t, float64(matches)/100000, tc.targetFPR+5*sigma)
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, SaveFilter(&buf, filter))
+		loaded, err := LoadFilter(&buf)
+		require.NoError(t, err)
+		require.Equal(t, filter, loaded)
+	}
+
+	for _, target := range []float64{0, 1, -1, 1e-12} {
+		_, err := NewFilterForFPR(large, target)
+		require.Error(t, err)
+	}
+}
+
+func TestLoadFilterErrors(t *testing.T) {
+	filter, err := PopulateBinaryFuse8([]uint64{1, 2, 3})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, SaveFilter(&buf, filter))
+	data := buf.Bytes()
+	loaded, err := LoadFilter(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, (*BinaryFuse[uint8])(filter), loaded)
+
+	corrupt := bytes.Clone(data)
+	corrupt[0] = 'Y'
+	_, err = LoadFilter(bytes.NewReader(corrupt))
+	require.Error(t, err)
+	corrupt = bytes.Clone(data)
+	corrupt[4] = 99
+	_, err = LoadFilter(bytes.NewReader(corrupt))
+	require.Error(t, err)
+	_, err = LoadFilter(bytes.NewReader(data[:20]))
+	require.Error(t, err)
+	require.Error(t, SaveFilter(&buf, &RetainedBinaryFuse[uint8]{}))
+}
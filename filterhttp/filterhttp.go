@@ -0,0 +1,193 @@
+// Package filterhttp serves a registry of named filters over HTTP, so that
+// remote nodes can download them and tools can query them.
+//
+// The handler answers two requests:
+//
+//	GET /{name}            the filter as written by its Save method
+//	POST /{name}/contains  the membership of a batch of keys
+//
+// Filter downloads carry an ETag, the SHA-256 hash of the serialized filter,
+// and support conditional and range requests. Mount the handler under a
+// prefix with http.StripPrefix.
+//
+// A batch of keys is either a JSON object {"keys": [1, 2, 3]}, answered with
+// {"results": [true, false, true]}, or, with the application/octet-stream
+// content type, the keys as little endian uint64 values, answered with one
+// bit per key: bit i%8 of byte i/8 is set if key i may be in the filter.
+package filterhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// Registry holds the filters served by a handler, by name. It is safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	filters map[string]*entry
+}
+
+// entry is a registered filter with its serialized form.
+type entry struct {
+	filter  xorfilter.Filter
+	data    []byte
+	etag    string
+	modTime time.Time
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{filters: make(map[string]*entry)}
+}
+
+// Set registers the filter under the name, replacing any previous filter.
+// The filter is serialized once, here, and must not be modified afterwards.
+func (r *Registry) Set(name string, filter xorfilter.Filter) error {
+	var buf bytes.Buffer
+	if err := filter.Save(&buf); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	e := &entry{
+		filter:  filter,
+		data:    buf.Bytes(),
+		etag:    `"` + hex.EncodeToString(sum[:]) + `"`,
+		modTime: time.Now(),
+	}
+	r.mu.Lock()
+	r.filters[name] = e
+	r.mu.Unlock()
+	return nil
+}
+
+// Remove unregisters the filter with the name, if any.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	delete(r.filters, name)
+	r.mu.Unlock()
+}
+
+func (r *Registry) get(name string) *entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.filters[name]
+}
+
+// Options configures a handler.
+type Options struct {
+	// MaxKeys is the largest number of keys accepted in a single contains
+	// request. Defaults to 65536.
+	MaxKeys int
+}
+
+// NewHandler returns a handler serving the filters of the registry.
+func NewHandler(r *Registry, opts Options) http.Handler {
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 65536
+	}
+	h := &handler{registry: r, maxKeys: opts.MaxKeys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{name}", h.serveFilter)
+	mux.HandleFunc("POST /{name}/contains", h.serveContains)
+	return mux
+}
+
+type handler struct {
+	registry *Registry
+	maxKeys  int
+}
+
+func (h *handler) serveFilter(w http.ResponseWriter, req *http.Request) {
+	e := h.registry.get(req.PathValue("name"))
+	if e == nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", e.etag)
+	http.ServeContent(w, req, "", e.modTime, bytes.NewReader(e.data))
+}
+
+// containsRequest and containsResponse are the JSON bodies of a contains
+// request.
+type containsRequest struct {
+	Keys []uint64 `json:"keys"`
+}
+
+type containsResponse struct {
+	Results []bool `json:"results"`
+}
+
+func (h *handler) serveContains(w http.ResponseWriter, req *http.Request) {
+	e := h.registry.get(req.PathValue("name"))
+	if e == nil {
+		http.NotFound(w, req)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		// A key takes at most 20 digits and a separator.
+		body := http.MaxBytesReader(w, req.Body, int64(h.maxKeys)*21+1024)
+		var request containsRequest
+		if err := json.NewDecoder(body).Decode(&request); err != nil {
+			httpError(w, err)
+			return
+		}
+		if len(request.Keys) > h.maxKeys {
+			http.Error(w, "too many keys", http.StatusRequestEntityTooLarge)
+			return
+		}
+		response := containsResponse{Results: make([]bool, len(request.Keys))}
+		for i, key := range request.Keys {
+			response.Results[i] = e.filter.Contains(key)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case "application/octet-stream":
+		body := http.MaxBytesReader(w, req.Body, int64(h.maxKeys)*8)
+		data, err := io.ReadAll(body)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		if len(data)%8 != 0 {
+			http.Error(w, "the body is not a sequence of uint64 keys", http.StatusBadRequest)
+			return
+		}
+		n := len(data) / 8
+		bits := make([]byte, (n+7)/8)
+		for i := 0; i < n; i++ {
+			if e.filter.Contains(binary.LittleEndian.Uint64(data[8*i:])) {
+				bits[i/8] |= 1 << (i % 8)
+			}
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(bits)
+	default:
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+	}
+}
+
+// httpError reports an error reading a request body.
+func httpError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "too many keys", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+}
@@ -0,0 +1,133 @@
+package filterhttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T, opts Options) (*Registry, *httptest.Server, []uint64) {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := xorfilter.NewBinaryFuse[uint16](keys)
+	require.NoError(t, err)
+	r := NewRegistry()
+	require.NoError(t, r.Set("users", filter))
+	server := httptest.NewServer(http.StripPrefix("/filters", NewHandler(r, opts)))
+	t.Cleanup(server.Close)
+	return r, server, keys
+}
+
+func get(t *testing.T, url, etag string) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestServeFilter(t *testing.T) {
+	r, server, keys := newServer(t, Options{})
+
+	resp, body := get(t, server.URL+"/filters/users", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	filter, err := xorfilter.LoadBinaryFuse[uint16](bytes.NewReader(body))
+	require.NoError(t, err)
+	for _, key := range keys {
+		require.True(t, filter.Contains(key))
+	}
+
+	resp, body = get(t, server.URL+"/filters/users", etag)
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Empty(t, body)
+
+	// Replacing the filter changes its ETag.
+	other, err := xorfilter.NewBinaryFuse[uint16]([]uint64{1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, r.Set("users", other))
+	resp, _ = get(t, server.URL+"/filters/users", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	resp, _ = get(t, server.URL+"/filters/missing", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	r.Remove("users")
+	resp, _ = get(t, server.URL+"/filters/users", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func post(t *testing.T, url, contentType string, body []byte) (*http.Response, []byte) {
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, data
+}
+
+func TestServeContains(t *testing.T) {
+	_, server, keys := newServer(t, Options{MaxKeys: 100})
+	url := server.URL + "/filters/users/contains"
+	// Half members, half random keys that are almost certainly not members.
+	batch := make([]uint64, 0, 20)
+	for i := range 10 {
+		batch = append(batch, keys[i], rand.Uint64())
+	}
+
+	request, err := json.Marshal(containsRequest{Keys: batch})
+	require.NoError(t, err)
+	resp, body := post(t, url, "application/json; charset=utf-8", request)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var response containsResponse
+	require.NoError(t, json.Unmarshal(body, &response))
+	require.Len(t, response.Results, len(batch))
+	for i := range batch {
+		require.Equal(t, i%2 == 0, response.Results[i], "key %d", i)
+	}
+
+	binaryRequest := binary.LittleEndian.AppendUint64(nil, batch[0])
+	for _, key := range batch[1:] {
+		binaryRequest = binary.LittleEndian.AppendUint64(binaryRequest, key)
+	}
+	resp, body = post(t, url, "application/octet-stream", binaryRequest)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []byte{0x55, 0x55, 0x05}, body)
+
+	resp, _ = post(t, url, "application/octet-stream", binaryRequest[:12])
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = post(t, url, "application/json", []byte("{"))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = post(t, url, "text/plain", request)
+	require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	resp, _ = post(t, server.URL+"/filters/missing/contains", "application/json", request)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	tooMany, err := json.Marshal(containsRequest{Keys: make([]uint64, 101)})
+	require.NoError(t, err)
+	resp, _ = post(t, url, "application/json", tooMany)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	resp, _ = post(t, url, "application/json", []byte(`{"keys": [`+strings.Repeat("1,", 10000)+`1]}`))
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	resp, _ = post(t, url, "application/octet-stream", make([]byte, 808))
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
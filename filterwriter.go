@@ -0,0 +1,73 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// FilterWriter builds BinaryFuse8 filter blocks over byte keys, for storage
+// engines that write one filter per table or data block, as with the filter
+// policies of Pebble or LevelDB. Keys are hashed with xxhash; MayContain
+// queries the resulting blocks.
+//
+// The zero value is ready to use. A FilterWriter reuses its memory across
+// blocks, and is not safe for concurrent use.
+type FilterWriter struct {
+	hashes  []uint64
+	builder BinaryFuseBuilder
+}
+
+// AddKey adds a key to the current block. Consecutive duplicate keys are
+// only added once.
+func (w *FilterWriter) AddKey(key []byte) {
+	hash := xxhash.Sum64(key)
+	if n := len(w.hashes); n > 0 && w.hashes[n-1] == hash {
+		return
+	}
+	w.hashes = append(w.hashes, hash)
+}
+
+// Finish appends the filter block over the added keys to buf and returns the
+// extended buffer. The writer is then reset for the next block.
+//
+// The block is a BinaryFuse8 as written by Save. In the unlikely event that
+// the filter cannot be built, nothing is appended: MayContain treats an
+// empty block as matching every key.
+func (w *FilterWriter) Finish(buf []byte) []byte {
+	defer func() { w.hashes = w.hashes[:0] }()
+	filter, err := BuildBinaryFuse[uint8](&w.builder, w.hashes)
+	if err != nil {
+		return buf
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, filter.Seed)
+	buf = binary.LittleEndian.AppendUint32(buf, filter.SegmentLength)
+	buf = binary.LittleEndian.AppendUint32(buf, filter.SegmentLengthMask)
+	buf = binary.LittleEndian.AppendUint32(buf, filter.SegmentCount)
+	buf = binary.LittleEndian.AppendUint32(buf, filter.SegmentCountLength)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(filter.Fingerprints)))
+	return append(buf, filter.Fingerprints...)
+}
+
+// MayContain reports whether key may be one of the keys of a block written
+// by FilterWriter.Finish, with a false positive probability of about 0.4%.
+// It reads the block in place, without allocating. A malformed block matches
+// every key, so that a corrupted filter never hides a key.
+func MayContain(filterBlock, key []byte) bool {
+	if len(filterBlock) < headerSize {
+		return true
+	}
+	filter := BinaryFuse[uint8]{
+		Seed:               binary.LittleEndian.Uint64(filterBlock[0:]),
+		SegmentLength:      binary.LittleEndian.Uint32(filterBlock[8:]),
+		SegmentLengthMask:  binary.LittleEndian.Uint32(filterBlock[12:]),
+		SegmentCount:       binary.LittleEndian.Uint32(filterBlock[16:]),
+		SegmentCountLength: binary.LittleEndian.Uint32(filterBlock[20:]),
+		Fingerprints:       filterBlock[headerSize:],
+	}
+	fpLen := binary.LittleEndian.Uint32(filterBlock[24:])
+	if uint64(fpLen) != uint64(len(filter.Fingerprints)) || filter.Validate() != nil {
+		return true
+	}
+	return filter.Contains(xxhash.Sum64(key))
+}
@@ -0,0 +1,61 @@
+package xorfilter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterWriter(t *testing.T) {
+	var w FilterWriter
+	prefix := []byte("block header")
+	var blocks [][]byte
+	for block := 0; block < 3; block++ {
+		for i := 0; i < 10000; i++ {
+			key := []byte(fmt.Sprintf("block%d/key%d", block, i))
+			w.AddKey(key)
+			w.AddKey(key)
+		}
+		buf := w.Finish(bytes.Clone(prefix))
+		require.Equal(t, prefix, buf[:len(prefix)])
+		blocks = append(blocks, buf[len(prefix):])
+	}
+
+	for block, filterBlock := range blocks {
+		loaded, err := LoadBinaryFuse[uint8](bytes.NewReader(filterBlock))
+		require.NoError(t, err)
+		// The writer was reset between blocks.
+		require.Less(t, loaded.MaxKeys(), uint32(11000))
+
+		for i := 0; i < 10000; i++ {
+			require.True(t, MayContain(filterBlock, []byte(fmt.Sprintf("block%d/key%d", block, i))))
+		}
+		matches := 0
+		for i := 0; i < 100000; i++ {
+			if MayContain(filterBlock, []byte(fmt.Sprintf("other%d", i))) {
+				matches++
+			}
+		}
+		require.Less(t, matches, 600)
+	}
+
+	key := []byte("block0/key1")
+	allocs := testing.AllocsPerRun(100, func() {
+		MayContain(blocks[0], key)
+	})
+	require.Zero(t, allocs)
+
+	// Malformed blocks match every key.
+	require.True(t, MayContain(nil, []byte("absent")))
+	require.True(t, MayContain(blocks[0][:len(blocks[0])-1], []byte("absent")))
+	corrupt := bytes.Clone(blocks[0])
+	corrupt[12]++
+	require.True(t, MayContain(corrupt, []byte("absent")))
+
+	// An empty block is valid.
+	empty := w.Finish(nil)
+	require.NotEmpty(t, empty)
+	require.False(t, MayContain(empty, []byte("absent")))
+}
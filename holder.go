@@ -0,0 +1,147 @@
+package xorfilter
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HolderOptions configures a Holder.
+type HolderOptions[T Unsigned] struct {
+	// Check, if not nil, is called on a reloaded filter before it is
+	// published, for example to verify that known keys are present. A
+	// non-nil error keeps the current filter.
+	Check func(*BinaryFuse[T]) error
+	// OnSwap, if not nil, is called after a new filter is published, by Swap
+	// or by a reload, with the previous and the new filter.
+	OnSwap func(old, new *BinaryFuse[T])
+	// OnError, if not nil, is called with the errors of the reloads made by
+	// Watch.
+	OnError func(error)
+	// LoadOptions is used when reading a filter from a file.
+	LoadOptions LoadOptions
+}
+
+// Holder holds the current version of a filter that is replaced at runtime.
+// Readers get the filter with an atomic load, so that Contains never waits
+// for a replacement. A Holder is safe for concurrent use.
+type Holder[T Unsigned] struct {
+	filter atomic.Pointer[BinaryFuse[T]]
+	opts   HolderOptions[T]
+
+	// mu serializes replacements, so that OnSwap calls are ordered.
+	mu sync.Mutex
+	// modTime and size describe the last file read by ReloadFrom, whether or
+	// not it was valid, so that Watch only reads a file again when it changes.
+	modTime time.Time
+	size    int64
+}
+
+// NewHolder creates a holder of the filter, which may be nil until a filter
+// is swapped in or loaded.
+func NewHolder[T Unsigned](filter *BinaryFuse[T], opts HolderOptions[T]) *Holder[T] {
+	h := &Holder[T]{opts: opts}
+	h.filter.Store(filter)
+	return h
+}
+
+// Load returns the current filter, or nil if there is none. The filter must
+// not be modified.
+func (h *Holder[T]) Load() *BinaryFuse[T] {
+	return h.filter.Load()
+}
+
+// Contains returns `true` if key is part of the set of the current filter
+// with a false positive probability, and `false` if there is no filter.
+func (h *Holder[T]) Contains(key uint64) bool {
+	f := h.filter.Load()
+	return f != nil && f.Contains(key)
+}
+
+// Swap publishes the filter and returns the previous one. The filter must
+// not be modified afterwards.
+func (h *Holder[T]) Swap(filter *BinaryFuse[T]) *BinaryFuse[T] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.swap(filter)
+}
+
+func (h *Holder[T]) swap(filter *BinaryFuse[T]) *BinaryFuse[T] {
+	old := h.filter.Swap(filter)
+	if h.opts.OnSwap != nil {
+		h.opts.OnSwap(old, filter)
+	}
+	return old
+}
+
+// ReloadFrom reads a filter saved by BinaryFuse[T].Save or SaveCompressed
+// from the file at path and publishes it. The filter is validated, and
+// checked with HolderOptions.Check, before being published: on error, the
+// current filter is kept.
+//
+// Replace the file atomically, by writing a temporary file and renaming it,
+// so that a reload never reads a partially written filter.
+func (h *Holder[T]) ReloadFrom(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	h.modTime, h.size = info.ModTime(), info.Size()
+	filter, err := LoadBinaryFuseWithOptions[T](file, h.opts.LoadOptions)
+	if err != nil {
+		return err
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	if h.opts.Check != nil {
+		if err := h.opts.Check(filter); err != nil {
+			return err
+		}
+	}
+	h.swap(filter)
+	return nil
+}
+
+// Watch checks the modification time and size of the file at path every
+// interval, and reloads it with ReloadFrom when they change, until the
+// context is done. The file is loaded immediately unless ReloadFrom already
+// read this version of it. Errors are passed to HolderOptions.OnError, and
+// the current filter is kept; Watch only returns the error of the context.
+func (h *Holder[T]) Watch(ctx context.Context, path string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := h.reloadIfChanged(path); err != nil && h.opts.OnError != nil {
+			h.opts.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Holder[T]) reloadIfChanged(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	unchanged := info.ModTime().Equal(h.modTime) && info.Size() == h.size
+	h.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	return h.ReloadFrom(path)
+}
@@ -0,0 +1,137 @@
+package xorfilter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFilterFile(t *testing.T, path string, filter *BinaryFuse[uint8], modTime time.Time) {
+	var buf bytes.Buffer
+	require.NoError(t, filter.Save(&buf))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func randomFilter(t *testing.T, n int) ([]uint64, *BinaryFuse[uint8]) {
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := NewBinaryFuse[uint8](keys)
+	require.NoError(t, err)
+	return keys, filter
+}
+
+func TestHolder(t *testing.T) {
+	keys1, filter1 := randomFilter(t, 1000)
+	keys2, filter2 := randomFilter(t, 2000)
+
+	var swaps [][2]*BinaryFuse[uint8]
+	h := NewHolder[uint8](nil, HolderOptions[uint8]{
+		OnSwap: func(old, new *BinaryFuse[uint8]) { swaps = append(swaps, [2]*BinaryFuse[uint8]{old, new}) },
+		Check: func(f *BinaryFuse[uint8]) error {
+			if !f.Contains(keys2[0]) {
+				return errors.New("missing key")
+			}
+			return nil
+		},
+	})
+	require.Nil(t, h.Load())
+	require.False(t, h.Contains(keys1[0]))
+
+	require.Nil(t, h.Swap(filter1))
+	require.Same(t, filter1, h.Load())
+	for _, key := range keys1 {
+		require.True(t, h.Contains(key))
+	}
+
+	path := filepath.Join(t.TempDir(), "filter.bin")
+	require.Error(t, h.ReloadFrom(path))
+
+	// The check rejects filter1, which does not contain keys2[0].
+	writeFilterFile(t, path, filter1, time.Unix(1000, 0))
+	require.Error(t, h.ReloadFrom(path))
+	require.Same(t, filter1, h.Load())
+
+	writeFilterFile(t, path, filter2, time.Unix(2000, 0))
+	require.NoError(t, h.ReloadFrom(path))
+	require.Equal(t, filter2, h.Load())
+	for _, key := range keys2 {
+		require.True(t, h.Contains(key))
+	}
+
+	// A corrupt file keeps the current filter.
+	require.NoError(t, os.WriteFile(path, []byte("corrupt"), 0o644))
+	require.Error(t, h.ReloadFrom(path))
+	require.Equal(t, filter2, h.Load())
+
+	require.Len(t, swaps, 2)
+	require.Nil(t, swaps[0][0])
+	require.Same(t, filter1, swaps[0][1])
+	require.Same(t, filter1, swaps[1][0])
+	require.Same(t, h.Load(), swaps[1][1])
+}
+
+func TestHolderWatch(t *testing.T) {
+	keys1, filter1 := randomFilter(t, 1000)
+	keys2, filter2 := randomFilter(t, 1000)
+	path := filepath.Join(t.TempDir(), "filter.bin")
+	writeFilterFile(t, path, filter1, time.Unix(1000, 0))
+
+	swapped := make(chan *BinaryFuse[uint8], 10)
+	errs := make(chan error, 10)
+	h := NewHolder[uint8](nil, HolderOptions[uint8]{
+		OnSwap:  func(_, new *BinaryFuse[uint8]) { swapped <- new },
+		OnError: func(err error) { errs <- err },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- h.Watch(ctx, path, time.Millisecond) }()
+
+	// Readers run concurrently with the reloads.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					h.Contains(keys1[0])
+				}
+			}
+		}()
+	}
+
+	require.Equal(t, filter1, <-swapped)
+	require.True(t, h.Contains(keys1[0]))
+
+	// The size is unchanged, so the modification time alone triggers a reload.
+	writeFilterFile(t, path, filter2, time.Unix(2000, 0))
+	require.Equal(t, filter2, <-swapped)
+	for _, key := range keys2 {
+		require.True(t, h.Contains(key))
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("corrupt"), 0o644))
+	require.Error(t, <-errs)
+	require.Equal(t, filter2, h.Load())
+
+	close(stop)
+	wg.Wait()
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Empty(t, swapped)
+}
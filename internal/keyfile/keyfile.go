@@ -0,0 +1,108 @@
+// Package keyfile reads newline-delimited keys for the command-line tools and
+// turns them into the 64-bit keys expected by the filters.
+package keyfile
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Format is the representation of the keys, one per line.
+type Format string
+
+const (
+	// Decimal keys are unsigned 64-bit integers in base 10.
+	Decimal Format = "uint"
+	// Hex keys are 64-bit hashes in base 16, with an optional 0x prefix.
+	Hex Format = "hex"
+	// String keys are arbitrary strings, hashed with a Hasher.
+	String Format = "string"
+)
+
+// Hasher maps a string key to a 64-bit key.
+type Hasher func(string) uint64
+
+// Hashers are the hashers available for String keys, by name.
+var Hashers = map[string]Hasher{
+	"xxhash": xxhash.Sum64String,
+	"fnv1a": func(s string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		return h.Sum64()
+	},
+}
+
+// Parser converts lines to keys.
+type Parser struct {
+	format Format
+	hasher Hasher
+}
+
+// NewParser returns a parser for the given format. The hasher name is only
+// used with the String format.
+func NewParser(format Format, hasher string) (*Parser, error) {
+	p := &Parser{format: format}
+	switch format {
+	case Decimal, Hex:
+	case String:
+		if p.hasher = Hashers[hasher]; p.hasher == nil {
+			return nil, fmt.Errorf("unknown hasher %q", hasher)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key format %q", format)
+	}
+	return p, nil
+}
+
+// Parse converts a single line, without its line terminator, to a key.
+func (p *Parser) Parse(line string) (uint64, error) {
+	switch p.format {
+	case Decimal:
+		return strconv.ParseUint(strings.TrimSpace(line), 10, 64)
+	case Hex:
+		s := strings.TrimSpace(line)
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+		return strconv.ParseUint(s, 16, 64)
+	default:
+		return p.hasher(line), nil
+	}
+}
+
+// Scan calls fn for each non-empty line of r, with the line and its key. Lines
+// may end with "\n" or "\r\n".
+func (p *Parser) Scan(r io.Reader, fn func(line string, key uint64) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		key, err := p.Parse(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if err := fn(line, key); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadAll returns the keys of all the non-empty lines of r.
+func (p *Parser) ReadAll(r io.Reader) ([]uint64, error) {
+	var keys []uint64
+	err := p.Scan(r, func(_ string, key uint64) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
@@ -0,0 +1,36 @@
+package keyfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser(t *testing.T) {
+	p, err := NewParser(Decimal, "")
+	require.NoError(t, err)
+	keys, err := p.ReadAll(strings.NewReader("1\r\n\n18446744073709551615\n 42 \n"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 18446744073709551615, 42}, keys)
+	_, err = p.ReadAll(strings.NewReader("1\nx\n"))
+	require.ErrorContains(t, err, "line 2")
+
+	p, err = NewParser(Hex, "")
+	require.NoError(t, err)
+	keys, err = p.ReadAll(strings.NewReader("0xff\nFF\n"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{255, 255}, keys)
+
+	p, err = NewParser(String, "xxhash")
+	require.NoError(t, err)
+	keys, err = p.ReadAll(strings.NewReader("hello\r\nworld"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{xxhash.Sum64String("hello"), xxhash.Sum64String("world")}, keys)
+
+	_, err = NewParser(String, "md5")
+	require.Error(t, err)
+	_, err = NewParser("bytes", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// SecretKey is the key of a KeyedBinaryFuse. It is not part of the saved
+// filter, and must be kept secret.
+type SecretKey [16]byte
+
+// ErrSecretKeyRequired is returned when loading a filter saved by
+// KeyedBinaryFuse.Save without its secret key, for example with
+// LoadBinaryFuse.
+var ErrSecretKeyRequired = errors.New("filter requires a secret key")
+
+// ErrWrongSecretKey is returned by LoadKeyedBinaryFuse when the secret key is
+// not the one the filter was built with.
+var ErrWrongSecretKey = errors.New("wrong secret key for the filter")
+
+// A keyed filter starts with a check value derived from the secret key,
+// where the seed of a filter would be, followed by keyedMarker where the
+// segment length would be: segment lengths are powers of two, so the Load
+// functions of unkeyed filters detect it. The marker is followed by the
+// hash function, as a uint32, and the filter over the hashed keys, as written
+// by BinaryFuse[T].Save.
+const keyedMarker = 0xFFFFFFFF
+
+// keyedSipHash24 hashes the keys with SipHash-2-4.
+const keyedSipHash24 = 1
+
+// KeyedBinaryFuse is a binary fuse filter over keys hashed with SipHash-2-4
+// under a secret key. The seed of a filter is public, so anyone holding an
+// unkeyed filter can search offline for keys it accepts by mistake; without
+// the secret key, the false positives of a KeyedBinaryFuse cannot be
+// predicted, which matters for filters that answer queries chosen by an
+// adversary. Queries are slower, since each key is hashed with SipHash first.
+type KeyedBinaryFuse[T Unsigned] struct {
+	// BinaryFuse is the filter over the hashed keys.
+	BinaryFuse BinaryFuse[T]
+
+	secret SecretKey
+}
+
+// NewKeyedBinaryFuse creates a filter over the keys, hashed with the secret
+// key. The keys slice is not modified.
+func NewKeyedBinaryFuse[T Unsigned](secret SecretKey, keys []uint64) (*KeyedBinaryFuse[T], error) {
+	hashed := make([]uint64, len(keys))
+	for i, key := range keys {
+		hashed[i] = sipHash24(secret, key)
+	}
+	filter, err := NewBinaryFuse[T](hashed)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyedBinaryFuse[T]{BinaryFuse: *filter, secret: secret}, nil
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability.
+func (f *KeyedBinaryFuse[T]) Contains(key uint64) bool {
+	return f.BinaryFuse.Contains(sipHash24(f.secret, key))
+}
+
+// Save writes the filter to the writer, without its secret key. Only
+// LoadKeyedBinaryFuse reads it: the other Load functions fail with
+// ErrSecretKeyRequired.
+func (f *KeyedBinaryFuse[T]) Save(w io.Writer) error {
+	header := binary.LittleEndian.AppendUint64(nil, keyCheck(f.secret))
+	header = binary.LittleEndian.AppendUint32(header, keyedMarker)
+	header = binary.LittleEndian.AppendUint32(header, keyedSipHash24)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	return f.BinaryFuse.Save(w)
+}
+
+// LoadKeyedBinaryFuse reads a filter saved by KeyedBinaryFuse.Save, which
+// must have been built with the given secret key.
+func LoadKeyedBinaryFuse[T Unsigned](r io.Reader, secret SecretKey) (*KeyedBinaryFuse[T], error) {
+	return LoadKeyedBinaryFuseWithOptions[T](r, secret, LoadOptions{})
+}
+
+// LoadKeyedBinaryFuseWithOptions is LoadKeyedBinaryFuse with restrictions on
+// the filters it accepts.
+func LoadKeyedBinaryFuseWithOptions[T Unsigned](r io.Reader, secret SecretKey, opts LoadOptions) (*KeyedBinaryFuse[T], error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(header[8:]) != keyedMarker {
+		return nil, errors.New("not a keyed filter")
+	}
+	if hash := binary.LittleEndian.Uint32(header[12:]); hash != keyedSipHash24 {
+		return nil, fmt.Errorf("unknown keyed hash function %d", hash)
+	}
+	if binary.LittleEndian.Uint64(header[:]) != keyCheck(secret) {
+		return nil, ErrWrongSecretKey
+	}
+	filter, err := LoadBinaryFuseWithOptions[T](r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyedBinaryFuse[T]{BinaryFuse: *filter, secret: secret}, nil
+}
+
+// keyCheck identifies the secret key without revealing it.
+func keyCheck(secret SecretKey) uint64 {
+	return sipHash24(secret, 0x6b65792d63686563) // "key-chec"
+}
+
+// sipHash24 returns the SipHash-2-4 of the key, as 8 little endian bytes.
+func sipHash24(secret SecretKey, key uint64) uint64 {
+	k0 := binary.LittleEndian.Uint64(secret[:])
+	k1 := binary.LittleEndian.Uint64(secret[8:])
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	// The message is a single 8-byte block, followed by a final block
+	// holding its length.
+	for _, m := range [2]uint64{key, 8 << 56} {
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+	}
+	v2 ^= 0xff
+	for range 4 {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
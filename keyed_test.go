@@ -0,0 +1,68 @@
+package xorfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSipHash24(t *testing.T) {
+	// Reference test vector: key 00 01 ... 0f, message 00 01 ... 07.
+	var secret SecretKey
+	for i := range secret {
+		secret[i] = byte(i)
+	}
+	message := binary.LittleEndian.Uint64([]byte{0, 1, 2, 3, 4, 5, 6, 7})
+	require.Equal(t, uint64(0x93f5f5799a932462), sipHash24(secret, message))
+}
+
+func TestKeyedBinaryFuse(t *testing.T) {
+	var secret, other SecretKey
+	for i := range secret {
+		secret[i] = byte(rand.Uint32())
+		other[i] = byte(rand.Uint32())
+	}
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := NewKeyedBinaryFuse[uint8](secret, keys)
+	require.NoError(t, err)
+	for _, key := range keys {
+		require.True(t, filter.Contains(key))
+	}
+	matches := 0
+	for range 100000 {
+		if filter.Contains(rand.Uint64()) {
+			matches++
+		}
+	}
+	require.Less(t, matches, 600)
+
+	var buf bytes.Buffer
+	require.NoError(t, filter.Save(&buf))
+	data := buf.Bytes()
+	loaded, err := LoadKeyedBinaryFuse[uint8](bytes.NewReader(data), secret)
+	require.NoError(t, err)
+	require.Equal(t, filter, loaded)
+	for _, key := range keys {
+		require.True(t, loaded.Contains(key))
+	}
+
+	_, err = LoadKeyedBinaryFuse[uint8](bytes.NewReader(data), other)
+	require.ErrorIs(t, err, ErrWrongSecretKey)
+	_, err = LoadBinaryFuse[uint8](bytes.NewReader(data))
+	require.ErrorIs(t, err, ErrSecretKeyRequired)
+	_, err = LoadBinaryFuse8(bytes.NewReader(data))
+	require.ErrorIs(t, err, ErrSecretKeyRequired)
+	_, err = OpenLazyBinaryFuse[uint8](bytes.NewReader(data), LazyOptions{})
+	require.ErrorIs(t, err, ErrSecretKeyRequired)
+
+	var unkeyed bytes.Buffer
+	require.NoError(t, filter.BinaryFuse.Save(&unkeyed))
+	_, err = LoadKeyedBinaryFuse[uint8](&unkeyed, secret)
+	require.Error(t, err)
+}
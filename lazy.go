@@ -0,0 +1,198 @@
+package xorfilter
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"unsafe"
+)
+
+// LazyOptions configures a LazyBinaryFuse.
+type LazyOptions struct {
+	// CachePages is the number of pages kept in memory, least recently used
+	// first out. Zero disables the cache: each query then reads its three
+	// fingerprints individually.
+	CachePages int
+	// PageSize is the size in bytes of the cached pages. Defaults to 4096.
+	PageSize int
+}
+
+// LazyBinaryFuse queries a filter saved by BinaryFuse[T].Save without
+// loading it: Contains reads the three fingerprints it needs from an
+// io.ReaderAt, such as an *os.File. Use io.NewSectionReader for a filter
+// that does not start at offset zero. The compressed format is not
+// supported, since it does not allow random access.
+//
+// A LazyBinaryFuse is safe for concurrent use if its io.ReaderAt is.
+type LazyBinaryFuse[T Unsigned] struct {
+	// filter holds the parameters, without fingerprints.
+	filter BinaryFuse[T]
+	r      io.ReaderAt
+	size   int64
+
+	pageSize int64
+	mu       sync.Mutex
+	cache    *pageCache
+}
+
+// OpenLazyBinaryFuse reads and validates the header of the filter. The
+// fingerprints are read from r on each query, so r must remain readable for
+// as long as the filter is used.
+func OpenLazyBinaryFuse[T Unsigned](r io.ReaderAt, opts LazyOptions) (*LazyBinaryFuse[T], error) {
+	var header [headerSize]byte
+	if n, err := r.ReadAt(header[:], 0); n < len(header) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch binary.LittleEndian.Uint32(header[8:]) {
+	case compressedMarker:
+		return nil, errors.New("compressed filters cannot be queried lazily")
+	case keyedMarker:
+		return nil, ErrSecretKeyRequired
+	}
+	f, fpLen := parseHeader[T](&header)
+	if err := checkLoad(f, fpLen, nil); err != nil {
+		return nil, err
+	}
+	l := &LazyBinaryFuse[T]{
+		filter:   *f,
+		r:        r,
+		size:     headerSize + int64(fpLen)*int64(unsafe.Sizeof(T(0))),
+		pageSize: int64(opts.PageSize),
+	}
+	if l.pageSize <= 0 {
+		l.pageSize = 4096
+	}
+	if opts.CachePages > 0 {
+		l.cache = newPageCache(opts.CachePages)
+	}
+	return l, nil
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability, or an error if the fingerprints could not be read.
+func (l *LazyBinaryFuse[T]) Contains(key uint64) (bool, error) {
+	hash := mixsplit(key, l.filter.Seed)
+	f := T(fingerprint(hash))
+	h0, h1, h2 := l.filter.getHashFromHash(hash)
+	for _, h := range [3]uint32{h0, h1, h2} {
+		fp, err := l.fingerprint(h)
+		if err != nil {
+			return false, err
+		}
+		f ^= fp
+	}
+	return f == 0, nil
+}
+
+// fingerprint returns the fingerprint at the given index.
+func (l *LazyBinaryFuse[T]) fingerprint(index uint32) (T, error) {
+	size := int64(unsafe.Sizeof(T(0)))
+	offset := headerSize + int64(index)*size
+	var buf [4]byte
+	if l.cache == nil {
+		if err := l.readAt(buf[:size], offset); err != nil {
+			return 0, err
+		}
+	} else {
+		page := offset / l.pageSize
+		data, err := l.page(page)
+		if err != nil {
+			return 0, err
+		}
+		// Fingerprints do not straddle pages when the page size is a
+		// multiple of the fingerprint size; otherwise read across them.
+		start := offset - page*l.pageSize
+		n := copy(buf[:size], data[start:])
+		if int64(n) < size {
+			next, err := l.page(page + 1)
+			if err != nil {
+				return 0, err
+			}
+			copy(buf[n:size], next)
+		}
+	}
+	switch size {
+	case 1:
+		return T(buf[0]), nil
+	case 2:
+		return T(binary.LittleEndian.Uint16(buf[:])), nil
+	default:
+		return T(binary.LittleEndian.Uint32(buf[:])), nil
+	}
+}
+
+// page returns the cached page, reading it if needed.
+func (l *LazyBinaryFuse[T]) page(page int64) ([]byte, error) {
+	l.mu.Lock()
+	data, ok := l.cache.get(page)
+	l.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	start := page * l.pageSize
+	data = make([]byte, min(l.pageSize, l.size-start))
+	if err := l.readAt(data, start); err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	l.cache.put(page, data)
+	l.mu.Unlock()
+	return data, nil
+}
+
+func (l *LazyBinaryFuse[T]) readAt(buf []byte, offset int64) error {
+	n, err := l.r.ReadAt(buf, offset)
+	if n == len(buf) {
+		// ReadAt may return io.EOF along with the last bytes.
+		return nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// pageCache is a least recently used cache of pages. It is not safe for
+// concurrent use.
+type pageCache struct {
+	capacity int
+	order    *list.List // of *cachedPage, most recently used first
+	pages    map[int64]*list.Element
+}
+
+type cachedPage struct {
+	index int64
+	data  []byte
+}
+
+func newPageCache(capacity int) *pageCache {
+	return &pageCache{capacity: capacity, order: list.New(), pages: make(map[int64]*list.Element)}
+}
+
+func (c *pageCache) get(index int64) ([]byte, bool) {
+	e, ok := c.pages[index]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cachedPage).data, true
+}
+
+func (c *pageCache) put(index int64, data []byte) {
+	if e, ok := c.pages[index]; ok {
+		// Another query read the page concurrently.
+		c.order.MoveToFront(e)
+		return
+	}
+	c.pages[index] = c.order.PushFront(&cachedPage{index: index, data: data})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.pages, oldest.Value.(*cachedPage).index)
+	}
+}
@@ -0,0 +1,88 @@
+package xorfilter
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingReaderAt counts the calls to ReadAt.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads.Add(1)
+	return c.r.ReadAt(p, off)
+}
+
+func testLazy[T Unsigned](t *testing.T, keys []uint64) {
+	filter, err := NewBinaryFuse[T](keys)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, filter.Save(&buf))
+	data := buf.Bytes()
+
+	for _, opts := range []LazyOptions{{}, {CachePages: 4}, {CachePages: 1 << 20, PageSize: 7}} {
+		r := &countingReaderAt{r: bytes.NewReader(data)}
+		lazy, err := OpenLazyBinaryFuse[T](r, opts)
+		require.NoError(t, err)
+		for _, key := range keys {
+			ok, err := lazy.Contains(key)
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		for range 10000 {
+			key := rand.Uint64()
+			ok, err := lazy.Contains(key)
+			require.NoError(t, err)
+			require.Equal(t, filter.Contains(key), ok)
+		}
+		queries := int64(len(keys) + 10000)
+		switch opts.CachePages {
+		case 0:
+			require.Equal(t, 1+3*queries, r.reads.Load())
+		case 4:
+			require.Less(t, r.reads.Load(), 3*queries)
+		default:
+			// Each page is read once.
+			require.LessOrEqual(t, r.reads.Load(), 1+int64(len(data)+6)/7)
+		}
+	}
+
+	// Truncated fingerprints are reported by the queries that need them.
+	lazy, err := OpenLazyBinaryFuse[T](bytes.NewReader(data[:len(data)/2]), LazyOptions{})
+	require.NoError(t, err)
+	failures := 0
+	for _, key := range keys {
+		if _, err := lazy.Contains(key); err != nil {
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+			failures++
+		}
+	}
+	require.Positive(t, failures)
+}
+
+func TestLazyBinaryFuse(t *testing.T) {
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	testLazy[uint8](t, keys)
+	testLazy[uint16](t, keys)
+	testLazy[uint32](t, keys)
+
+	filter, err := NewBinaryFuse[uint8](keys)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, filter.SaveCompressed(&buf))
+	_, err = OpenLazyBinaryFuse[uint8](bytes.NewReader(buf.Bytes()), LazyOptions{})
+	require.Error(t, err)
+	_, err = OpenLazyBinaryFuse[uint8](bytes.NewReader(buf.Bytes()[:10]), LazyOptions{})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,358 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+// A filter pack stores named filters in a single file:
+//
+//	header  "XPAK" and the format version, as a uint32
+//	filters each written by its Save method, starting at a multiple of 8 bytes
+//	TOC     for each filter: the length of its name as a uint16, the name,
+//	        its FilterType as a uint32, its offset and length as uint64
+//	        values and the CRC-32C of its bytes as a uint32
+//	footer  the offset of the TOC as a uint64, the number of filters and the
+//	        CRC-32C of the TOC as uint32 values, and "XPAK"
+//
+// All the numbers are little endian. Since the TOC is at the end, a pack is
+// written in a single pass.
+var packMagic = [4]byte{'X', 'P', 'A', 'K'}
+
+const (
+	packVersion    = 1
+	packHeaderSize = 8
+	packFooterSize = 20
+	// packMinEntrySize is the size of a TOC entry with a one-byte name.
+	packMinEntrySize = 2 + 1 + 4 + 8 + 8 + 4
+)
+
+var packTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrFilterNotFound is returned by Pack.Filter for a name that is not in the
+// pack.
+var ErrFilterNotFound = errors.New("filter not found")
+
+// PackEntry describes a filter of a pack.
+type PackEntry struct {
+	Name string
+	Type FilterType
+	// Offset and Length locate the filter, as written by its Save method,
+	// in the pack.
+	Offset uint64
+	Length uint64
+	// Checksum is the CRC-32C of the filter bytes.
+	Checksum uint32
+}
+
+// PackWriter writes a filter pack. Filters are written as they are added,
+// so that only the table of contents is kept in memory.
+type PackWriter struct {
+	w       *offsetWriter
+	entries []PackEntry
+	names   map[string]bool
+	// err is the first write error, returned by all later calls.
+	err error
+}
+
+// offsetWriter counts the bytes written to the underlying writer.
+type offsetWriter struct {
+	w      io.Writer
+	offset uint64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.offset += uint64(n)
+	return n, err
+}
+
+// NewPackWriter writes the header of a pack to w and returns a writer for
+// its filters. Consider buffering w: filters are written with many small
+// writes.
+func NewPackWriter(w io.Writer) (*PackWriter, error) {
+	var header [packHeaderSize]byte
+	copy(header[:], packMagic[:])
+	binary.LittleEndian.PutUint32(header[4:], packVersion)
+	p := &PackWriter{w: &offsetWriter{w: w}, names: make(map[string]bool)}
+	if _, err := p.w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Add writes the filter to the pack under the name, which must be unique and
+// at most 65535 bytes long.
+func (p *PackWriter) Add(name string, f Filter) error {
+	if p.err != nil {
+		return p.err
+	}
+	if name == "" || len(name) > math.MaxUint16 {
+		return errors.New("filter names must have 1 to 65535 bytes")
+	}
+	if p.names[name] {
+		return fmt.Errorf("duplicate filter name %q", name)
+	}
+	t, err := FilterTypeOf(f)
+	if err != nil {
+		return err
+	}
+	// Align the filters, so that their fingerprints can be used in place.
+	var padding [8]byte
+	if _, err := p.w.Write(padding[:(8-p.w.offset%8)%8]); err != nil {
+		p.err = err
+		return err
+	}
+	entry := PackEntry{Name: name, Type: t, Offset: p.w.offset}
+	h := crc32.New(packTable)
+	if err := f.Save(io.MultiWriter(p.w, h)); err != nil {
+		p.err = err
+		return err
+	}
+	entry.Length = p.w.offset - entry.Offset
+	entry.Checksum = h.Sum32()
+	p.entries = append(p.entries, entry)
+	p.names[name] = true
+	return nil
+}
+
+// Close writes the table of contents and the footer of the pack. It does not
+// close the underlying writer.
+func (p *PackWriter) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+	var toc []byte
+	for _, e := range p.entries {
+		toc = binary.LittleEndian.AppendUint16(toc, uint16(len(e.Name)))
+		toc = append(toc, e.Name...)
+		toc = binary.LittleEndian.AppendUint32(toc, uint32(e.Type))
+		toc = binary.LittleEndian.AppendUint64(toc, e.Offset)
+		toc = binary.LittleEndian.AppendUint64(toc, e.Length)
+		toc = binary.LittleEndian.AppendUint32(toc, e.Checksum)
+	}
+	footer := binary.LittleEndian.AppendUint64(nil, p.w.offset)
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(p.entries)))
+	footer = binary.LittleEndian.AppendUint32(footer, crc32.Checksum(toc, packTable))
+	footer = append(footer, packMagic[:]...)
+	if _, err := p.w.Write(append(toc, footer...)); err != nil {
+		p.err = err
+		return err
+	}
+	p.err = errors.New("pack writer is closed")
+	return nil
+}
+
+// Pack gives random access to the filters of a pack held in memory. OpenPack
+// maps the file in memory where possible, and the filters returned by Filter
+// use the pack memory directly rather than copies of their fingerprints: they
+// must not be modified, and must not be used after Close.
+//
+// A Pack is safe for concurrent use.
+type Pack struct {
+	data    []byte
+	entries []PackEntry
+	index   map[string]int
+	// filters[i] is the result of Filter for entries[i], so that checksums
+	// are only verified once.
+	filters []packFilter
+	release func() error
+}
+
+type packFilter struct {
+	once   sync.Once
+	filter Filter
+	err    error
+}
+
+// OpenPack opens the pack file at path. The file is mapped in memory on the
+// systems supporting it, and read otherwise.
+func OpenPack(path string) (*Pack, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, release, err := mapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPack(data)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	p.release = release
+	return p, nil
+}
+
+// NewPack reads the table of contents of the pack held in data. The checksum
+// of each filter is only verified by the first call to Filter for it.
+func NewPack(data []byte) (*Pack, error) {
+	if len(data) < packHeaderSize+packFooterSize ||
+		[4]byte(data[:4]) != packMagic || [4]byte(data[len(data)-4:]) != packMagic {
+		return nil, errors.New("not a filter pack")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != packVersion {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	footer := data[len(data)-packFooterSize:]
+	tocOffset := binary.LittleEndian.Uint64(footer)
+	numEntries := binary.LittleEndian.Uint32(footer[8:])
+	end := uint64(len(data) - packFooterSize)
+	if tocOffset < packHeaderSize || tocOffset > end {
+		return nil, errors.New("invalid table of contents offset")
+	}
+	toc := data[tocOffset:end]
+	if crc32.Checksum(toc, packTable) != binary.LittleEndian.Uint32(footer[12:]) {
+		return nil, errors.New("table of contents checksum mismatch")
+	}
+	if uint64(numEntries) > uint64(len(toc))/packMinEntrySize {
+		return nil, errors.New("invalid number of filters")
+	}
+
+	p := &Pack{data: data, entries: make([]PackEntry, 0, numEntries), index: make(map[string]int, numEntries)}
+	for i := uint32(0); i < numEntries; i++ {
+		if len(toc) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		nameLen := int(binary.LittleEndian.Uint16(toc))
+		if nameLen == 0 || len(toc) < 2+nameLen+24 {
+			return nil, errors.New("invalid table of contents entry")
+		}
+		e := PackEntry{Name: string(toc[2 : 2+nameLen])}
+		fields := toc[2+nameLen:]
+		e.Type = FilterType(binary.LittleEndian.Uint32(fields))
+		e.Offset = binary.LittleEndian.Uint64(fields[4:])
+		e.Length = binary.LittleEndian.Uint64(fields[12:])
+		e.Checksum = binary.LittleEndian.Uint32(fields[20:])
+		toc = fields[24:]
+		if e.Offset < packHeaderSize || e.Offset > tocOffset || e.Length > tocOffset-e.Offset {
+			return nil, fmt.Errorf("filter %q is out of bounds", e.Name)
+		}
+		if _, ok := p.index[e.Name]; ok {
+			return nil, fmt.Errorf("duplicate filter name %q", e.Name)
+		}
+		p.index[e.Name] = len(p.entries)
+		p.entries = append(p.entries, e)
+	}
+	if len(toc) != 0 {
+		return nil, errors.New("unexpected data after the table of contents")
+	}
+	p.filters = make([]packFilter, len(p.entries))
+	return p, nil
+}
+
+// Entries returns the filters of the pack, in the order they were added.
+func (p *Pack) Entries() []PackEntry {
+	return p.entries
+}
+
+// Filter returns the named filter, whose checksum is verified on the first
+// call for it: later calls return the same filter, or the same error. The
+// result is of the type recorded in the pack, as with LoadFilter.
+func (p *Pack) Filter(name string) (Filter, error) {
+	i, ok := p.index[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrFilterNotFound, name)
+	}
+	pf := &p.filters[i]
+	pf.once.Do(func() {
+		pf.filter, pf.err = p.view(p.entries[i])
+	})
+	return pf.filter, pf.err
+}
+
+// view verifies the checksum of the filter of the entry and returns it.
+func (p *Pack) view(e PackEntry) (Filter, error) {
+	data := p.data[e.Offset : e.Offset+e.Length]
+	if crc32.Checksum(data, packTable) != e.Checksum {
+		return nil, fmt.Errorf("filter %q: checksum mismatch", e.Name)
+	}
+	f, err := viewFilter(e.Type, data)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", e.Name, err)
+	}
+	return f, nil
+}
+
+// Close releases the memory of the pack.
+func (p *Pack) Close() error {
+	if p.release == nil {
+		return nil
+	}
+	release := p.release
+	p.release = nil
+	return release()
+}
+
+// viewFilter returns the filter of the given type written by Save in data,
+// with fingerprints sharing the memory of data when possible.
+func viewFilter(t FilterType, data []byte) (Filter, error) {
+	switch t {
+	case TypeXor8:
+		if len(data) < xor8HeaderSize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		f := &Xor8{Seed: binary.LittleEndian.Uint64(data), BlockLength: binary.LittleEndian.Uint32(data[8:])}
+		fpLen := binary.LittleEndian.Uint32(data[12:])
+		if err := checkXor8Header(f.BlockLength, fpLen); err != nil {
+			return nil, err
+		}
+		if uint64(len(data)-xor8HeaderSize) != uint64(fpLen) {
+			return nil, errors.New("fingerprint length does not match the filter size")
+		}
+		f.Fingerprints = data[xor8HeaderSize:len(data):len(data)]
+		return f, nil
+	case TypeBinaryFuse8:
+		return asFilter(viewBinaryFuse[uint8](data))
+	case TypeBinaryFuse16:
+		return asFilter(viewBinaryFuse[uint16](data))
+	case TypeBinaryFuse32:
+		return asFilter(viewBinaryFuse[uint32](data))
+	case TypeBinaryFusePacked:
+		if len(data) < packedHeaderSize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		f := &BinaryFusePacked{
+			Seed:               binary.LittleEndian.Uint64(data),
+			SegmentLength:      binary.LittleEndian.Uint32(data[8:]),
+			SegmentLengthMask:  binary.LittleEndian.Uint32(data[12:]),
+			SegmentCount:       binary.LittleEndian.Uint32(data[16:]),
+			SegmentCountLength: binary.LittleEndian.Uint32(data[20:]),
+			Bits:               binary.LittleEndian.Uint32(data[24:]),
+		}
+		wordsLen := binary.LittleEndian.Uint32(data[28:])
+		if err := checkPackedHeader(f, wordsLen); err != nil {
+			return nil, err
+		}
+		if uint64(len(data)-packedHeaderSize) != 8*uint64(wordsLen) {
+			return nil, errors.New("fingerprint length does not match the filter size")
+		}
+		f.Fingerprints = viewSlice[uint64](data[packedHeaderSize:])
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown filter type %d", uint32(t))
+}
+
+func viewBinaryFuse[T Unsigned](data []byte) (*BinaryFuse[T], error) {
+	if len(data) < headerSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	f, fpLen := parseHeader[T]((*[headerSize]byte)(data))
+	if err := checkLoad(f, fpLen, nil); err != nil {
+		return nil, err
+	}
+	if uint64(len(data)-headerSize) != uint64(fpLen)*uint64(unsafe.Sizeof(T(0))) {
+		return nil, errors.New("fingerprint length does not match the filter size")
+	}
+	f.Fingerprints = viewSlice[T](data[headerSize:])
+	return f, nil
+}
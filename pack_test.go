@@ -0,0 +1,164 @@
+package xorfilter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPack(t *testing.T) {
+	keys := make([]uint64, 5000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	xor, err := Populate(keys)
+	require.NoError(t, err)
+	fuse8, err := PopulateBinaryFuse8(keys[:100])
+	require.NoError(t, err)
+	fuse16, err := NewBinaryFuse[uint16](keys[:3])
+	require.NoError(t, err)
+	fuse32, err := NewBinaryFuse[uint32](keys)
+	require.NoError(t, err)
+	packed, err := NewBinaryFusePacked(keys, 11)
+	require.NoError(t, err)
+	filters := map[string]Filter{
+		"xor":    xor,
+		"fuse8":  fuse8,
+		"fuse16": fuse16,
+		"fuse32": fuse32,
+		"packed": packed,
+	}
+	names := []string{"xor", "fuse8", "fuse16", "fuse32", "packed"}
+
+	path := filepath.Join(t.TempDir(), "filters.pack")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	buffered := bufio.NewWriter(file)
+	w, err := NewPackWriter(buffered)
+	require.NoError(t, err)
+	for _, name := range names {
+		require.NoError(t, w.Add(name, filters[name]))
+	}
+	require.Error(t, w.Add("xor", xor))
+	require.Error(t, w.Add("", xor))
+	require.Error(t, w.Add("retained", &RetainedBinaryFuse[uint8]{}))
+	require.NoError(t, w.Close())
+	require.Error(t, w.Add("late", xor))
+	require.NoError(t, buffered.Flush())
+	require.NoError(t, file.Close())
+
+	p, err := OpenPack(path)
+	require.NoError(t, err)
+	entries := p.Entries()
+	require.Len(t, entries, len(names))
+	for i, e := range entries {
+		require.Equal(t, names[i], e.Name)
+		require.Zero(t, e.Offset%8)
+		expected, err := FilterTypeOf(filters[e.Name])
+		require.NoError(t, err)
+		require.Equal(t, expected, e.Type)
+	}
+	for _, name := range names {
+		f, err := p.Filter(name)
+		require.NoError(t, err)
+		if name == "fuse8" {
+			require.Equal(t, (*BinaryFuse[uint8])(fuse8), f)
+		} else {
+			require.Equal(t, filters[name], f)
+		}
+	}
+	f, err := p.Filter("fuse32")
+	require.NoError(t, err)
+	for _, key := range keys {
+		require.True(t, f.Contains(key))
+	}
+	_, err = p.Filter("missing")
+	require.ErrorIs(t, err, ErrFilterNotFound)
+	require.NoError(t, p.Close())
+	require.NoError(t, p.Close())
+}
+
+func newTestPack(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := NewPackWriter(&buf)
+	require.NoError(t, err)
+	for i, n := range []int{10, 1000} {
+		keys := make([]uint64, n)
+		for j := range keys {
+			keys[j] = rand.Uint64()
+		}
+		f, err := NewBinaryFuse[uint16](keys)
+		require.NoError(t, err)
+		require.NoError(t, w.Add(string(rune('a'+i)), f))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestPackZeroCopy(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("fingerprints are copied on big endian systems")
+	}
+	data := newTestPack(t)
+	p, err := NewPack(data)
+	require.NoError(t, err)
+	f, err := p.Filter("b")
+	require.NoError(t, err)
+	fingerprints := f.(*BinaryFuse[uint16]).Fingerprints
+	start := uintptr(unsafe.Pointer(&data[0]))
+	fp := uintptr(unsafe.Pointer(&fingerprints[0]))
+	require.True(t, fp > start && fp < start+uintptr(len(data)))
+
+	// The checksum is verified once: later calls return the same view, even
+	// though the data no longer matches the checksum.
+	fingerprints[0] ^= 1
+	again, err := p.Filter("b")
+	require.NoError(t, err)
+	require.Same(t, f, again)
+}
+
+func TestPackCorrupt(t *testing.T) {
+	data := newTestPack(t)
+	p, err := NewPack(data)
+	require.NoError(t, err)
+	b := p.Entries()[1]
+
+	// A corrupted filter only affects that filter.
+	corrupt := bytes.Clone(data)
+	corrupt[b.Offset+b.Length-1] ^= 1
+	p, err = NewPack(corrupt)
+	require.NoError(t, err)
+	_, err = p.Filter("b")
+	require.ErrorContains(t, err, "checksum")
+	_, err = p.Filter("a")
+	require.NoError(t, err)
+
+	// A corrupted table of contents, truncated pack or bad header is
+	// rejected.
+	corrupt = bytes.Clone(data)
+	corrupt[len(corrupt)-packFooterSize-1] ^= 1
+	_, err = NewPack(corrupt)
+	require.Error(t, err)
+	for _, n := range []int{0, 10, len(data) - 1} {
+		_, err = NewPack(data[:n])
+		require.Error(t, err)
+	}
+	corrupt = bytes.Clone(data)
+	corrupt[4] = 2
+	_, err = NewPack(corrupt)
+	require.Error(t, err)
+	corrupt = bytes.Clone(data)
+	binary.LittleEndian.PutUint32(corrupt[len(corrupt)-12:], 1<<30)
+	_, err = NewPack(corrupt)
+	require.Error(t, err)
+
+	_, err = OpenPack(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
@@ -0,0 +1,232 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxShards bounds the number of shards of a PartitionedFilter.
+const maxShards = 1 << 24
+
+// PartitionedFilter routes keys by the high bits of their hash into
+// independently built BinaryFuse[T] shards. Each query then touches a single
+// shard, which is much smaller than a filter over the whole set. Shards can
+// be built in parallel, rebuilt individually when their keys change, and
+// loaded lazily from a file.
+//
+// Queries are safe for concurrent use, but Rebuild must not be called
+// concurrently with other methods.
+type PartitionedFilter[T Unsigned] struct {
+	shards []atomic.Pointer[BinaryFuse[T]]
+
+	// Lazy loading state, used when the filter was opened from a file. A
+	// shard that failed to load is retried on the next access.
+	r       io.ReaderAt
+	offsets []uint64
+	loadMu  []sync.Mutex
+}
+
+// ShardIndex returns the shard, in [0, numShards), that key belongs to.
+func ShardIndex(key uint64, numShards int) int {
+	return int(reduce(uint32(murmur64(key)>>32), uint32(numShards)))
+}
+
+// PartitionKeys splits keys into numShards slices according to ShardIndex.
+func PartitionKeys(keys []uint64, numShards int) [][]uint64 {
+	counts := make([]int, numShards)
+	for _, key := range keys {
+		counts[ShardIndex(key, numShards)]++
+	}
+	parts := make([][]uint64, numShards)
+	for i := range parts {
+		parts[i] = make([]uint64, 0, counts[i])
+	}
+	for _, key := range keys {
+		i := ShardIndex(key, numShards)
+		parts[i] = append(parts[i], key)
+	}
+	return parts
+}
+
+// NewPartitionedFilter creates a filter with numShards shards over the
+// provided keys, building the shards in parallel. The keys slice is not
+// modified.
+func NewPartitionedFilter[T Unsigned](keys []uint64, numShards int) (*PartitionedFilter[T], error) {
+	if numShards < 1 || numShards > maxShards {
+		return nil, errors.New("invalid number of shards")
+	}
+	p := &PartitionedFilter[T]{shards: make([]atomic.Pointer[BinaryFuse[T]], numShards)}
+	parts := PartitionKeys(keys, numShards)
+	dirty := make(map[int][]uint64, numShards)
+	for i, part := range parts {
+		dirty[i] = part
+	}
+	if err := p.build(dirty); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NumShards returns the number of shards.
+func (p *PartitionedFilter[T]) NumShards() int {
+	return len(p.shards)
+}
+
+// Rebuild replaces the given shards, in parallel, with filters built from
+// their new key sets. All the keys of a shard must belong to it according to
+// ShardIndex. The key slices are not modified.
+func (p *PartitionedFilter[T]) Rebuild(shardKeys map[int][]uint64) error {
+	for i, keys := range shardKeys {
+		if i < 0 || i >= len(p.shards) {
+			return fmt.Errorf("shard %d out of range", i)
+		}
+		for _, key := range keys {
+			if ShardIndex(key, len(p.shards)) != i {
+				return fmt.Errorf("key %d does not belong to shard %d", key, i)
+			}
+		}
+	}
+	return p.build(shardKeys)
+}
+
+// build builds the given shards in parallel and installs them once all of
+// them succeeded.
+func (p *PartitionedFilter[T]) build(shardKeys map[int][]uint64) error {
+	jobs := make(chan int, len(shardKeys))
+	for i := range shardKeys {
+		jobs <- i
+	}
+	close(jobs)
+	built := make([]*BinaryFuse[T], len(p.shards))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var buildErr error
+	for w := min(runtime.GOMAXPROCS(0), len(shardKeys)); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var b BinaryFuseBuilder
+			for i := range jobs {
+				filter, err := buildOwned[T](&b, shardKeys[i])
+				if err != nil {
+					mu.Lock()
+					buildErr = errors.Join(buildErr, fmt.Errorf("shard %d: %w", i, err))
+					mu.Unlock()
+					continue
+				}
+				built[i] = &filter
+			}
+		}()
+	}
+	wg.Wait()
+	if buildErr != nil {
+		return buildErr
+	}
+	for i := range shardKeys {
+		p.shards[i].Store(built[i])
+	}
+	return nil
+}
+
+// Shard returns shard i, loading it first if needed.
+func (p *PartitionedFilter[T]) Shard(i int) (*BinaryFuse[T], error) {
+	if shard := p.shards[i].Load(); shard != nil || p.r == nil {
+		return shard, nil
+	}
+	p.loadMu[i].Lock()
+	defer p.loadMu[i].Unlock()
+	if shard := p.shards[i].Load(); shard != nil {
+		return shard, nil
+	}
+	section := io.NewSectionReader(p.r, int64(p.offsets[i]), int64(p.offsets[i+1]-p.offsets[i]))
+	shard, err := LoadBinaryFuse[T](section)
+	if err != nil {
+		return nil, err
+	}
+	p.shards[i].Store(shard)
+	return shard, nil
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability. If the shard of the key cannot be loaded, Contains returns
+// `true`: like a false positive, it does not cause a false negative. Use
+// Shard to check for loading errors.
+func (p *PartitionedFilter[T]) Contains(key uint64) bool {
+	shard, err := p.Shard(ShardIndex(key, len(p.shards)))
+	if err != nil {
+		return true
+	}
+	return shard.Contains(key)
+}
+
+// Save writes the filter to the writer in little endian format: the number
+// of shards, an index of numShards+1 offsets from the start of the output,
+// then each shard as written by BinaryFuse[T].Save.
+func (p *PartitionedFilter[T]) Save(w io.Writer) error {
+	shards := make([]*BinaryFuse[T], len(p.shards))
+	offsets := make([]uint64, len(p.shards)+1)
+	offsets[0] = 4 + 8*uint64(len(offsets))
+	for i := range shards {
+		shard, err := p.Shard(i)
+		if err != nil {
+			return err
+		}
+		shards[i] = shard
+		offsets[i+1] = offsets[i] + headerSize + uint64(len(shard.Fingerprints))*uint64(unsafe.Sizeof(T(0)))
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(p.shards))); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, offsets); err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if err := shard.Save(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenPartitionedFilter reads the shard index of a filter written by Save.
+// Shards are only read from r when they are first queried, so r must remain
+// readable for as long as the filter is used.
+func OpenPartitionedFilter[T Unsigned](r io.ReaderAt) (*PartitionedFilter[T], error) {
+	var header [4]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	numShards := binary.LittleEndian.Uint32(header[:])
+	if numShards == 0 || numShards > maxShards {
+		return nil, errors.New("invalid number of shards")
+	}
+	index := make([]byte, 8*(uint64(numShards)+1))
+	if _, err := r.ReadAt(index, 4); err != nil {
+		return nil, err
+	}
+	offsets := make([]uint64, numShards+1)
+	for i := range offsets {
+		offsets[i] = binary.LittleEndian.Uint64(index[8*i:])
+	}
+	if offsets[0] != 4+uint64(len(index)) {
+		return nil, errors.New("invalid shard index")
+	}
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] || offsets[i] > math.MaxInt64 {
+			return nil, errors.New("invalid shard index")
+		}
+	}
+	return &PartitionedFilter[T]{
+		shards:  make([]atomic.Pointer[BinaryFuse[T]], numShards),
+		r:       r,
+		offsets: offsets,
+		loadMu:  make([]sync.Mutex, numShards),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package xorfilter
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionedFilter(t *testing.T) {
+	keys := make([]uint64, 100_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	p, err := NewPartitionedFilter[uint16](keys, 16)
+	require.NoError(t, err)
+	for _, v := range keys {
+		require.True(t, p.Contains(v))
+	}
+
+	// Rebuild a single shard with a different key set.
+	parts := PartitionKeys(keys, p.NumShards())
+	before, err := p.Shard(3)
+	require.NoError(t, err)
+	extra := uint64(0)
+	for ShardIndex(extra, p.NumShards()) != 3 {
+		extra++
+	}
+	parts[3] = append(parts[3], extra)
+	require.NoError(t, p.Rebuild(map[int][]uint64{3: parts[3]}))
+	after, err := p.Shard(3)
+	require.NoError(t, err)
+	require.NotEqual(t, before, after)
+	require.True(t, p.Contains(extra))
+	for _, v := range keys {
+		require.True(t, p.Contains(v))
+	}
+
+	// Keys must belong to the shard being rebuilt.
+	require.Error(t, p.Rebuild(map[int][]uint64{4: parts[3]}))
+}
+
+func TestPartitionedFilterSerialization(t *testing.T) {
+	keys := make([]uint64, 50_000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	p, err := NewPartitionedFilter[uint8](keys, 7)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Save(&buf))
+	loaded, err := OpenPartitionedFilter[uint8](bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 7, loaded.NumShards())
+	for i := 0; i < loaded.NumShards(); i++ {
+		require.Nil(t, loaded.shards[i].Load(), "shard %d loaded eagerly", i)
+	}
+	for _, v := range keys {
+		require.True(t, loaded.Contains(v))
+	}
+	for i := 0; i < p.NumShards(); i++ {
+		expected, _ := p.Shard(i)
+		actual, err := loaded.Shard(i)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+
+	// Saving a lazily loaded filter gives the same bytes.
+	reopened, err := OpenPartitionedFilter[uint8](bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	var buf2 bytes.Buffer
+	require.NoError(t, reopened.Save(&buf2))
+	require.Equal(t, buf.Bytes(), buf2.Bytes())
+}
+
+type failingReaderAt struct {
+	data []byte
+	fail bool
+}
+
+func (f *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if f.fail {
+		return 0, errors.New("read failed")
+	}
+	return bytes.NewReader(f.data).ReadAt(p, off)
+}
+
+func TestPartitionedFilterLoadError(t *testing.T) {
+	keys := []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	p, err := NewPartitionedFilter[uint8](keys, 2)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, p.Save(&buf))
+
+	r := &failingReaderAt{data: buf.Bytes()}
+	loaded, err := OpenPartitionedFilter[uint8](r)
+	require.NoError(t, err)
+	r.fail = true
+	_, err = loaded.Shard(0)
+	require.Error(t, err)
+	// No false negatives even if the shard cannot be read.
+	for _, v := range keys {
+		require.True(t, loaded.Contains(v))
+	}
+
+	// Loading is retried once the reader recovers.
+	r.fail = false
+	shard, err := loaded.Shard(0)
+	require.NoError(t, err)
+	expected, _ := p.Shard(0)
+	require.Equal(t, expected, shard)
+}
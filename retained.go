@@ -0,0 +1,148 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// RetainedBinaryFuse is a binary fuse filter that keeps its keys, sorted and
+// deduplicated, so that it can be combined with other filters or rebuilt
+// without the original data. The keys cost 64 bits each on top of the
+// fingerprints.
+type RetainedBinaryFuse[T Unsigned] struct {
+	BinaryFuse[T]
+
+	// Keys are the sorted, deduplicated keys of the filter.
+	Keys []uint64
+}
+
+// NewRetainedBinaryFuse creates a binary fuse filter with provided keys and
+// retains a sorted, deduplicated copy of them. The keys slice is not
+// modified.
+func NewRetainedBinaryFuse[T Unsigned](keys []uint64) (*RetainedBinaryFuse[T], error) {
+	var b BinaryFuseBuilder
+	return buildRetained[T](&b, sortedKeys(keys), nil)
+}
+
+// Union creates a filter over the union of the keys of the given filters.
+// The filters are not modified.
+func Union[T Unsigned](filters ...*RetainedBinaryFuse[T]) (*RetainedBinaryFuse[T], error) {
+	n := 0
+	for _, f := range filters {
+		n += len(f.Keys)
+	}
+	keys := make([]uint64, 0, n)
+	for _, f := range filters {
+		keys = append(keys, f.Keys...)
+	}
+	if len(keys) > 0 {
+		keys = pruneDuplicates(keys)
+	}
+	var b BinaryFuseBuilder
+	return buildRetained[T](&b, keys, nil)
+}
+
+// Update returns a filter over the keys of f plus added, minus removed; a key
+// that is both added and removed is removed. f is not modified.
+//
+// Buffers are reused from the BinaryFuseBuilder, and the seed of f is tried
+// first, so that the new filter usually has the same seed as f and, if the
+// number of keys keeps the same segment layout, the same parameters. Most
+// fingerprints still change, since each fingerprint depends on those assigned
+// before it. To keep deltas between versions small, split the keys with
+// PartitionedFilter: its Rebuild leaves the shards whose keys did not change
+// byte-identical.
+func (f *RetainedBinaryFuse[T]) Update(b *BinaryFuseBuilder, added, removed []uint64) (*RetainedBinaryFuse[T], error) {
+	added = sortedKeys(added)
+	removed = sortedKeys(removed)
+	keys := make([]uint64, 0, len(f.Keys)+len(added))
+	base := f.Keys
+	for len(base) > 0 || len(added) > 0 {
+		var key uint64
+		switch {
+		case len(added) == 0 || (len(base) > 0 && base[0] < added[0]):
+			key, base = base[0], base[1:]
+		case len(base) == 0 || added[0] < base[0]:
+			key, added = added[0], added[1:]
+		default:
+			key, base, added = base[0], base[1:], added[1:]
+		}
+		for len(removed) > 0 && removed[0] < key {
+			removed = removed[1:]
+		}
+		if len(removed) > 0 && removed[0] == key {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	seed := f.Seed
+	return buildRetained[T](b, keys, &seed)
+}
+
+// sortedKeys returns a sorted, deduplicated copy of keys.
+func sortedKeys(keys []uint64) []uint64 {
+	if len(keys) == 0 {
+		return nil
+	}
+	return pruneDuplicates(slices.Clone(keys))
+}
+
+// buildRetained builds the filter over sorted, deduplicated keys, which it
+// retains. If seed is not nil, it is tried first.
+func buildRetained[T Unsigned](b *BinaryFuseBuilder, keys []uint64, seed *uint64) (*RetainedBinaryFuse[T], error) {
+	filter, err := buildOwnedSeeded[T](b, keys, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &RetainedBinaryFuse[T]{BinaryFuse: filter, Keys: keys}, nil
+}
+
+// Save writes the filter in the format of BinaryFuse[T].Save, followed by the
+// number of keys as a uint64 and the sorted keys, in little endian format.
+func (f *RetainedBinaryFuse[T]) Save(w io.Writer) error {
+	if err := f.BinaryFuse.Save(w); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(f.Keys))); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, f.Keys)
+}
+
+// LoadRetainedBinaryFuse reads the filter from the reader in little endian
+// format. The keys must be sorted and distinct.
+func LoadRetainedBinaryFuse[T Unsigned](r io.Reader) (*RetainedBinaryFuse[T], error) {
+	return LoadRetainedBinaryFuseWithOptions[T](r, LoadOptions{})
+}
+
+// LoadRetainedBinaryFuseWithOptions is LoadRetainedBinaryFuse with
+// restrictions on the filters it accepts.
+func LoadRetainedBinaryFuseWithOptions[T Unsigned](r io.Reader, opts LoadOptions) (*RetainedBinaryFuse[T], error) {
+	budget := opts.budget()
+	filter, err := loadBinaryFuse[T](r, budget)
+	if err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	var numKeys uint64
+	if err := binary.Read(r, binary.LittleEndian, &numKeys); err != nil {
+		return nil, err
+	}
+	if err := spend[uint64](budget, numKeys, "keys"); err != nil {
+		return nil, err
+	}
+	keys, err := readSlice[uint64](r, numKeys)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			return nil, errors.New("retained keys are not sorted")
+		}
+	}
+	return &RetainedBinaryFuse[T]{BinaryFuse: *filter, Keys: keys}, nil
+}
@@ -0,0 +1,124 @@
+package xorfilter
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnion(t *testing.T) {
+	var filters []*RetainedBinaryFuse[uint16]
+	var all []uint64
+	for i := 0; i < 4; i++ {
+		keys := make([]uint64, 20000)
+		for j := range keys {
+			keys[j] = rand.Uint64()
+		}
+		// Overlap with the previous filter and duplicate keys.
+		keys = append(keys, all[max(0, len(all)-100):]...)
+		keys = append(keys, keys[:10]...)
+		input := slices.Clone(keys)
+		f, err := NewRetainedBinaryFuse[uint16](keys)
+		require.NoError(t, err)
+		require.Equal(t, input, keys)
+		require.True(t, slices.IsSorted(f.Keys))
+		require.Len(t, f.Keys, 20000+min(len(all), 100))
+		filters = append(filters, f)
+		all = append(all, keys[:20000]...)
+	}
+
+	union, err := Union(filters...)
+	require.NoError(t, err)
+	require.Len(t, union.Keys, len(all))
+	for _, key := range all {
+		require.True(t, union.Contains(key))
+	}
+	for _, f := range filters {
+		require.True(t, slices.IsSorted(f.Keys))
+	}
+
+	empty, err := Union[uint16]()
+	require.NoError(t, err)
+	require.Empty(t, empty.Keys)
+}
+
+func TestRetainedBinaryFuseSaveLoad(t *testing.T) {
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	f, err := NewRetainedBinaryFuse[uint8](keys)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, f.Save(&buf))
+	data := buf.Bytes()
+
+	loaded, err := LoadRetainedBinaryFuse[uint8](bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, f, loaded)
+
+	// The filter part is readable by LoadBinaryFuse.
+	filter, err := LoadBinaryFuse[uint8](bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, f.BinaryFuse, *filter)
+
+	_, err = LoadRetainedBinaryFuse[uint8](bytes.NewReader(data[:len(data)-1]))
+	require.Error(t, err)
+	unsorted := bytes.Clone(data)
+	copy(unsorted[len(unsorted)-8:], unsorted[len(unsorted)-16:len(unsorted)-8])
+	_, err = LoadRetainedBinaryFuse[uint8](bytes.NewReader(unsorted))
+	require.ErrorContains(t, err, "sorted")
+}
+
+func TestRetainedBinaryFuseUpdate(t *testing.T) {
+	keys := make([]uint64, 200000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	base, err := NewRetainedBinaryFuse[uint8](keys)
+	require.NoError(t, err)
+
+	// Change 0.1% of the keys.
+	removed := slices.Clone(keys[:100])
+	added := make([]uint64, 100)
+	for i := range added {
+		added[i] = rand.Uint64()
+	}
+	// A key both added and removed is removed.
+	added = append(added, removed[0])
+	input := slices.Clone(added)
+
+	var b BinaryFuseBuilder
+	updated, err := base.Update(&b, added, removed)
+	require.NoError(t, err)
+	require.Equal(t, input, added)
+	require.Equal(t, base.Seed, updated.Seed)
+	require.Equal(t, base.SegmentLength, updated.SegmentLength)
+	require.Len(t, updated.Keys, len(keys))
+	require.True(t, slices.IsSorted(updated.Keys))
+	for _, key := range keys[100:] {
+		require.True(t, updated.Contains(key))
+	}
+	for _, key := range added[:100] {
+		require.True(t, updated.Contains(key))
+	}
+	for _, key := range removed {
+		_, found := slices.BinarySearch(updated.Keys, key)
+		require.False(t, found)
+	}
+
+	// Rebuilding from scratch gives the same filter when the seeds match.
+	expected, err := NewRetainedBinaryFuse[uint8](updated.Keys)
+	require.NoError(t, err)
+	if expected.Seed == updated.Seed {
+		require.Equal(t, expected, updated)
+	}
+
+	// A no-op update reproduces the base filter.
+	same, err := base.Update(&b, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, base, same)
+}
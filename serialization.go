@@ -4,8 +4,10 @@
 package xorfilter
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io"
+	"unsafe"
 )
 
 // Save writes the filter to the writer in little endian format.
@@ -39,34 +41,27 @@ func (f *BinaryFuse[T]) Save(w io.Writer) error {
 	return nil
 }
 
-// LoadBinaryFuse reads the filter from the reader in little endian format.
-func LoadBinaryFuse[T Unsigned](r io.Reader) (*BinaryFuse[T], error) {
-	var f BinaryFuse[T]
-	if err := binary.Read(r, binary.LittleEndian, &f.Seed); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(r, binary.LittleEndian, &f.SegmentLength); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(r, binary.LittleEndian, &f.SegmentLengthMask); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(r, binary.LittleEndian, &f.SegmentCount); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(r, binary.LittleEndian, &f.SegmentCountLength); err != nil {
-		return nil, err
-	}
-	// Read the length of Fingerprints
-	var fpLen uint32
-	if err := binary.Read(r, binary.LittleEndian, &fpLen); err != nil {
-		return nil, err
-	}
-	f.Fingerprints = make([]T, fpLen)
-	for i := range f.Fingerprints {
-		if err := binary.Read(r, binary.LittleEndian, &f.Fingerprints[i]); err != nil {
-			return nil, err
-		}
+// readFingerprints reads n little endian fingerprints.
+func readFingerprints[T Unsigned](r io.Reader, n uint64) ([]T, error) {
+	return readSlice[T](r, n)
+}
+
+// viewSlice returns a copy of the little endian values of data.
+func viewSlice[T Unsigned | ~uint64](data []byte) []T {
+	n := len(data) / int(unsafe.Sizeof(T(0)))
+	if n == 0 {
+		return nil
 	}
-	return &f, nil
+	// Reading from a large enough bytes.Reader never fails.
+	values, _ := readSlice[T](bytes.NewReader(data), uint64(n))
+	return values
+}
+
+// littleEndianBytes returns the little endian encoding of *v, which holds
+// integers.
+func littleEndianBytes[V any](v *V) []byte {
+	var buf bytes.Buffer
+	// Writing integers to a bytes.Buffer never fails.
+	_ = binary.Write(&buf, binary.LittleEndian, v)
+	return buf.Bytes()
 }
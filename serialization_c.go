@@ -0,0 +1,86 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// CFingerprint is the fingerprint type of the binary fuse filters of the C
+// single-header library (https://github.com/FastFilter/xor_singleheader):
+// binary_fuse8_t or binary_fuse16_t.
+type CFingerprint interface {
+	uint8 | uint16
+}
+
+// The C library derives the three positions and the fingerprint of a key from
+// murmur64(key + seed) exactly as BinaryFuse[T] does, so a filter moves
+// between the two libraries by converting the serialized layout only. The
+// filters of the Java library (fastfilter_java) are out of scope: it maps
+// hashes to positions differently, so they cannot be converted.
+//
+// binary_fuse8_serialize and binary_fuse16_serialize write, in host byte
+// order, which is little endian on all the supported platforms:
+//
+//	uint64 Seed
+//	uint32 Size (number of keys)
+//	uint32 SegmentLength
+//	uint32 SegmentLengthMask
+//	uint32 SegmentCount
+//	uint32 SegmentCountLength
+//	uint32 ArrayLength (number of fingerprints)
+//	ArrayLength fingerprints
+const cHeaderSize = 8 + 6*4
+
+// SaveBinaryFuseC writes the filter in the layout of binary_fuse8_serialize
+// or binary_fuse16_serialize, so that the C library can deserialize it.
+// BinaryFuse[T] does not record its number of keys: size is written to the
+// Size field of the C structure, which the C library only uses to report the
+// filter size.
+func SaveBinaryFuseC[T CFingerprint](w io.Writer, filter *BinaryFuse[T], size uint32) error {
+	var header [cHeaderSize]byte
+	binary.LittleEndian.PutUint64(header[0:], filter.Seed)
+	binary.LittleEndian.PutUint32(header[8:], size)
+	binary.LittleEndian.PutUint32(header[12:], filter.SegmentLength)
+	binary.LittleEndian.PutUint32(header[16:], filter.SegmentLengthMask)
+	binary.LittleEndian.PutUint32(header[20:], filter.SegmentCount)
+	binary.LittleEndian.PutUint32(header[24:], filter.SegmentCountLength)
+	binary.LittleEndian.PutUint32(header[28:], uint32(len(filter.Fingerprints)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, filter.Fingerprints)
+}
+
+// LoadBinaryFuseC reads a filter serialized by binary_fuse8_serialize or
+// binary_fuse16_serialize, with T matching the C fingerprint type. It also
+// returns the Size field, the number of keys of the C filter. The layout is
+// validated before the fingerprints are read.
+func LoadBinaryFuseC[T CFingerprint](r io.Reader) (*BinaryFuse[T], uint32, error) {
+	return LoadBinaryFuseCWithOptions[T](r, LoadOptions{})
+}
+
+// LoadBinaryFuseCWithOptions is LoadBinaryFuseC with restrictions on the
+// filters it accepts.
+func LoadBinaryFuseCWithOptions[T CFingerprint](r io.Reader, opts LoadOptions) (*BinaryFuse[T], uint32, error) {
+	var header [cHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	f := &BinaryFuse[T]{
+		Seed:               binary.LittleEndian.Uint64(header[0:]),
+		SegmentLength:      binary.LittleEndian.Uint32(header[12:]),
+		SegmentLengthMask:  binary.LittleEndian.Uint32(header[16:]),
+		SegmentCount:       binary.LittleEndian.Uint32(header[20:]),
+		SegmentCountLength: binary.LittleEndian.Uint32(header[24:]),
+	}
+	size := binary.LittleEndian.Uint32(header[8:])
+	arrayLength := binary.LittleEndian.Uint32(header[28:])
+	if err := checkLoad(f, arrayLength, opts.budget()); err != nil {
+		return nil, 0, err
+	}
+	var err error
+	if f.Fingerprints, err = readSlice[T](r, uint64(arrayLength)); err != nil {
+		return nil, 0, err
+	}
+	return f, size, nil
+}
@@ -0,0 +1,83 @@
+package xorfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// goldenKeys are the keys of the filters in testdata: 0, 1, ..., 999. The
+// golden files must be written by the C library, with the program in
+// testdata/binary_fuse_c.c, so that the test checks the layout against the
+// C serializer rather than against SaveBinaryFuseC.
+func goldenKeys() []uint64 {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i)
+	}
+	return keys
+}
+
+func testGoldenC[T CFingerprint](t *testing.T, name string) {
+	path := filepath.Join("testdata", name)
+	keys := goldenKeys()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	filter, size, err := LoadBinaryFuseC[T](bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, uint32(len(keys)), size)
+	for _, key := range keys {
+		require.True(t, filter.Contains(key))
+	}
+
+	// Check the fields at the offsets of the C structure.
+	le := binary.LittleEndian
+	require.Equal(t, filter.Seed, le.Uint64(data[0:]))
+	require.Equal(t, size, le.Uint32(data[8:]))
+	require.Equal(t, filter.SegmentLength, le.Uint32(data[12:]))
+	require.Equal(t, filter.SegmentLengthMask, le.Uint32(data[16:]))
+	require.Equal(t, filter.SegmentCount, le.Uint32(data[20:]))
+	require.Equal(t, filter.SegmentCountLength, le.Uint32(data[24:]))
+	require.Equal(t, uint32(len(filter.Fingerprints)), le.Uint32(data[28:]))
+	require.Equal(t, 32+len(filter.Fingerprints)*len(data[32:])/len(filter.Fingerprints), len(data))
+
+	var buf bytes.Buffer
+	require.NoError(t, SaveBinaryFuseC(&buf, filter, size))
+	require.Equal(t, data, buf.Bytes())
+}
+
+func TestBinaryFuseCGolden(t *testing.T) {
+	testGoldenC[uint8](t, "binary_fuse8_c.bin")
+	testGoldenC[uint16](t, "binary_fuse16_c.bin")
+}
+
+func TestLoadBinaryFuseCCorrupt(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "binary_fuse8_c.bin"))
+	require.NoError(t, err)
+
+	_, _, err = LoadBinaryFuseC[uint8](bytes.NewReader(data[:20]))
+	require.Error(t, err)
+	_, _, err = LoadBinaryFuseC[uint8](bytes.NewReader(data[:len(data)-1]))
+	require.Error(t, err)
+
+	corrupt := bytes.Clone(data)
+	corrupt[16] ^= 1 // SegmentLengthMask
+	_, _, err = LoadBinaryFuseC[uint8](bytes.NewReader(corrupt))
+	require.Error(t, err)
+
+	// A huge ArrayLength with matching parameters is rejected by the short
+	// input without allocating the declared size.
+	corrupt = bytes.Clone(data[:32])
+	binary.LittleEndian.PutUint32(corrupt[12:], 1<<18)
+	binary.LittleEndian.PutUint32(corrupt[16:], 1<<18-1)
+	binary.LittleEndian.PutUint32(corrupt[20:], 16000)
+	binary.LittleEndian.PutUint32(corrupt[24:], 16000<<18)
+	binary.LittleEndian.PutUint32(corrupt[28:], 16002<<18)
+	_, _, err = LoadBinaryFuseC[uint16](bytes.NewReader(corrupt))
+	require.Error(t, err)
+}
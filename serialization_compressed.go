@@ -0,0 +1,125 @@
+package xorfilter
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A compressed filter starts with the seed, like an uncompressed one,
+// followed by compressedMarker where the segment length (or the block length
+// of an Xor8) would be. No uncompressed filter has a length of zero, so Load
+// functions detect the format from the header. The marker is followed by the
+// codec, the uncompressed header fields, the size of the compressed data as a
+// uint64 and the compressed fingerprints.
+const compressedMarker = 0
+
+// codecDeflate compresses the little endian fingerprints with DEFLATE.
+const codecDeflate = 1
+
+// Fingerprints are close to uniformly random, except for the slots left
+// empty during construction, which are zero: about 12% of the fingerprints
+// of a large binary fuse filter and 19% of those of an Xor8. DEFLATE
+// therefore saves between 5% (large binary fuse filters) and 10% of the size.
+
+// SaveCompressed writes the filter like Save, with DEFLATE-compressed
+// fingerprints. LoadBinaryFuse reads both formats.
+func (f *BinaryFuse[T]) SaveCompressed(w io.Writer) error {
+	header := []uint32{compressedMarker, codecDeflate, f.SegmentLength, f.SegmentLengthMask, f.SegmentCount, f.SegmentCountLength, uint32(len(f.Fingerprints))}
+	if err := binary.Write(w, binary.LittleEndian, f.Seed); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return writeDeflated(w, f.Fingerprints)
+}
+
+// SaveCompressed writes the filter like Save, with DEFLATE-compressed
+// fingerprints. LoadBinaryFuse8 reads both formats.
+func (f *BinaryFuse8) SaveCompressed(w io.Writer) error {
+	return (*BinaryFuse[uint8])(f).SaveCompressed(w)
+}
+
+// loadCompressedBinaryFuse reads the rest of a compressed filter, after its
+// seed and the marker.
+func loadCompressedBinaryFuse[T Unsigned](r io.Reader, seed uint64, budget *loadBudget) (*BinaryFuse[T], error) {
+	var header [6]uint32
+	if err := binary.Read(r, binary.LittleEndian, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != codecDeflate {
+		return nil, errors.New("unknown compression codec")
+	}
+	f := &BinaryFuse[T]{
+		Seed:               seed,
+		SegmentLength:      header[1],
+		SegmentLengthMask:  header[2],
+		SegmentCount:       header[3],
+		SegmentCountLength: header[4],
+	}
+	fpLen := header[5]
+	if err := checkLoad(f, fpLen, budget); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.Fingerprints, err = readDeflated[T](r, uint64(fpLen)); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// writeDeflated writes the size of the compressed values, as a uint64,
+// followed by the compressed little endian values.
+func writeDeflated[T Unsigned](w io.Writer, values []T) error {
+	var buf bytes.Buffer
+	zw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(zw, binary.LittleEndian, values); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(buf.Len())); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// readDeflated reads n values written by writeDeflated. It does not read
+// past the compressed data.
+func readDeflated[T Unsigned](r io.Reader, n uint64) ([]T, error) {
+	var size uint64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	limited := &io.LimitedReader{R: r, N: int64(min(size, 1<<62))}
+	zr := flate.NewReader(limited)
+	defer zr.Close()
+	values, err := readSlice[T](zr, n)
+	if err != nil {
+		return nil, err
+	}
+	// Consume the end of the stream, and the rest of the compressed data, so
+	// that the reader is positioned after it.
+	var extra [1]byte
+	if _, err := io.ReadFull(zr, extra[:]); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after the compressed values")
+		}
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, limited); err != nil {
+		return nil, err
+	}
+	if limited.N != 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return values, nil
+}
@@ -0,0 +1,92 @@
+package xorfilter
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCompressed[T Unsigned](t *testing.T, keys []uint64) {
+	filter, err := NewBinaryFuse[T](keys)
+	require.NoError(t, err)
+	var raw, compressed bytes.Buffer
+	require.NoError(t, filter.Save(&raw))
+	require.NoError(t, filter.SaveCompressed(&compressed))
+	require.Less(t, compressed.Len(), raw.Len())
+	t.Logf("%d keys, %d-byte fingerprints: %d bytes, compressed %d bytes (%.1f%%)", len(keys), len(raw.Bytes()[28:])/len(filter.Fingerprints),
+		raw.Len(), compressed.Len(), 100*float64(compressed.Len())/float64(raw.Len()))
+
+	// Both formats are detected, and a following value can still be read.
+	compressed.WriteString("next")
+	for _, buf := range []*bytes.Buffer{&raw, &compressed} {
+		loaded, err := LoadBinaryFuse[T](buf)
+		require.NoError(t, err)
+		require.Equal(t, filter, loaded)
+	}
+	require.Equal(t, "next", compressed.String())
+}
+
+func TestBinaryFuseCompressed(t *testing.T) {
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	testCompressed[uint8](t, keys)
+	testCompressed[uint16](t, keys)
+	testCompressed[uint32](t, keys)
+
+	filter, err := PopulateBinaryFuse8(keys)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, filter.SaveCompressed(&buf))
+	data := buf.Bytes()
+	loaded, err := LoadBinaryFuse8(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, filter, loaded)
+
+	for _, n := range []int{10, 40, len(data) / 2, len(data) - 1} {
+		_, err = LoadBinaryFuse8(bytes.NewReader(data[:n]))
+		require.Error(t, err)
+	}
+	corrupt := bytes.Clone(data)
+	corrupt[12] = 2 // codec
+	_, err = LoadBinaryFuse8(bytes.NewReader(corrupt))
+	require.ErrorContains(t, err, "codec")
+	corrupt = bytes.Clone(data)
+	corrupt[20]++ // segment length mask
+	_, err = LoadBinaryFuse8(bytes.NewReader(corrupt))
+	require.Error(t, err)
+}
+
+func TestXor8SaveLoad(t *testing.T) {
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := Populate(keys)
+	require.NoError(t, err)
+
+	var raw, compressed bytes.Buffer
+	require.NoError(t, filter.Save(&raw))
+	require.NoError(t, filter.SaveCompressed(&compressed))
+	require.Less(t, compressed.Len(), raw.Len()*95/100)
+	for _, buf := range []*bytes.Buffer{&raw, &compressed} {
+		data := buf.Bytes()
+		loaded, err := LoadXor8(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, filter, loaded)
+		for _, key := range keys {
+			require.True(t, loaded.Contains(key))
+		}
+		_, err = LoadXor8(bytes.NewReader(data[:len(data)-1]))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+
+	corrupt := bytes.Clone(raw.Bytes())
+	corrupt[8]++ // block length
+	_, err = LoadXor8(bytes.NewReader(corrupt))
+	require.Error(t, err)
+}
@@ -45,41 +45,35 @@ func (f *BinaryFuse[T]) Save(w io.Writer) error {
 	return nil
 }
 
-// LoadBinaryFuse reads the filter from the reader assuming little endian system, using direct byte copy for performance.
-func LoadBinaryFuse[T Unsigned](r io.Reader) (*BinaryFuse[T], error) {
-	var f BinaryFuse[T]
-	// Read Seed
-	if _, err := io.ReadFull(r, (*[8]byte)(unsafe.Pointer(&f.Seed))[:]); err != nil {
-		return nil, err
-	}
-	// Read SegmentLength
-	if _, err := io.ReadFull(r, (*[4]byte)(unsafe.Pointer(&f.SegmentLength))[:]); err != nil {
-		return nil, err
-	}
-	// Read SegmentLengthMask
-	if _, err := io.ReadFull(r, (*[4]byte)(unsafe.Pointer(&f.SegmentLengthMask))[:]); err != nil {
-		return nil, err
-	}
-	// Read SegmentCount
-	if _, err := io.ReadFull(r, (*[4]byte)(unsafe.Pointer(&f.SegmentCount))[:]); err != nil {
-		return nil, err
-	}
-	// Read SegmentCountLength
-	if _, err := io.ReadFull(r, (*[4]byte)(unsafe.Pointer(&f.SegmentCountLength))[:]); err != nil {
-		return nil, err
-	}
-	// Read length of Fingerprints
-	var fpLen uint32
-	if _, err := io.ReadFull(r, (*[4]byte)(unsafe.Pointer(&fpLen))[:]); err != nil {
-		return nil, err
+// readFingerprints reads n fingerprints assuming little endian system, using
+// direct byte copy for performance.
+func readFingerprints[T Unsigned](r io.Reader, n uint64) ([]T, error) {
+	size := int(unsafe.Sizeof(T(0)))
+	return readChunked(n, func(values []T) error {
+		bytes := unsafe.Slice((*byte)(unsafe.Pointer(&values[0])), len(values)*size)
+		_, err := io.ReadFull(r, bytes)
+		return err
+	})
+}
+
+// viewSlice returns the little endian values of data, sharing its memory
+// when data is suitably aligned for T.
+func viewSlice[T Unsigned | ~uint64](data []byte) []T {
+	size := int(unsafe.Sizeof(T(0)))
+	n := len(data) / size
+	if n == 0 {
+		return nil
 	}
-	f.Fingerprints = make([]T, fpLen)
-	if fpLen > 0 {
-		size := int(unsafe.Sizeof(T(0)))
-		bytes := unsafe.Slice((*byte)(unsafe.Pointer(&f.Fingerprints[0])), int(fpLen)*size)
-		if _, err := io.ReadFull(r, bytes); err != nil {
-			return nil, err
-		}
+	if uintptr(unsafe.Pointer(&data[0]))%uintptr(size) != 0 {
+		values := make([]T, n)
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(&values[0])), n*size), data)
+		return values
 	}
-	return &f, nil
+	return unsafe.Slice((*T)(unsafe.Pointer(&data[0])), n)
+}
+
+// littleEndianBytes returns the memory of *v, which holds integers, as its
+// little endian encoding.
+func littleEndianBytes[V any](v *V) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(v)), unsafe.Sizeof(*v))
 }
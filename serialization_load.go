@@ -0,0 +1,155 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// ErrTooLarge is returned when a filter exceeds LoadOptions.MaxBytes.
+var ErrTooLarge = errors.New("filter exceeds the size limit")
+
+// LoadOptions restricts the filters accepted by the load functions, for
+// filters received from untrusted sources. Each load function has a variant
+// taking LoadOptions, such as LoadBinaryFuseWithOptions or
+// LoadCascadeWithOptions.
+type LoadOptions struct {
+	// MaxBytes is the maximum size of the content of a filter, in bytes: its
+	// fingerprints, and the keys or exceptions of the filters holding them,
+	// over all the levels of a Cascade. Filters declaring more are rejected
+	// before it is allocated. Zero means no limit.
+	MaxBytes int64
+}
+
+// loadBudget holds the bytes a load function may still allocate for the
+// content of a filter. A nil budget has no limit.
+type loadBudget struct {
+	limit     int64
+	remaining int64
+}
+
+func (opts LoadOptions) budget() *loadBudget {
+	if opts.MaxBytes <= 0 {
+		return nil
+	}
+	return &loadBudget{limit: opts.MaxBytes, remaining: opts.MaxBytes}
+}
+
+// spend takes n values of type T out of the budget, or fails with
+// ErrTooLarge if they do not fit in it.
+func spend[T any](b *loadBudget, n uint64, what string) error {
+	if b == nil {
+		return nil
+	}
+	size := uint64(unsafe.Sizeof(*new(T)))
+	if n > uint64(b.remaining)/size {
+		return fmt.Errorf("%w: %d bytes of %s, limit %d", ErrTooLarge, n*size, what, b.limit)
+	}
+	b.remaining -= int64(n * size)
+	return nil
+}
+
+// LoadBinaryFuse reads the filter, saved by Save or SaveCompressed, from the
+// reader in little endian format.
+//
+// The header is validated before the fingerprints are read, and the
+// fingerprints are read incrementally: a truncated or corrupted input fails
+// without allocating the size it declares. Use LoadBinaryFuseWithOptions to
+// also bound the size of valid filters.
+func LoadBinaryFuse[T Unsigned](r io.Reader) (*BinaryFuse[T], error) {
+	return LoadBinaryFuseWithOptions[T](r, LoadOptions{})
+}
+
+// LoadBinaryFuseWithOptions is LoadBinaryFuse with restrictions on the
+// filters it accepts.
+func LoadBinaryFuseWithOptions[T Unsigned](r io.Reader, opts LoadOptions) (*BinaryFuse[T], error) {
+	return loadBinaryFuse[T](r, opts.budget())
+}
+
+func loadBinaryFuse[T Unsigned](r io.Reader, budget *loadBudget) (*BinaryFuse[T], error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:12]); err != nil {
+		return nil, err
+	}
+	seed := binary.LittleEndian.Uint64(header[0:])
+	switch binary.LittleEndian.Uint32(header[8:]) {
+	case compressedMarker:
+		return loadCompressedBinaryFuse[T](r, seed, budget)
+	case keyedMarker:
+		return nil, ErrSecretKeyRequired
+	}
+	if _, err := io.ReadFull(r, header[12:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	f, fpLen := parseHeader[T](&header)
+	if err := checkLoad(f, fpLen, budget); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.Fingerprints, err = readFingerprints[T](r, uint64(fpLen)); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// headerSize is the size of the header written by BinaryFuse[T].Save.
+const headerSize = 28
+
+// parseHeader decodes the header written by BinaryFuse[T].Save. It returns
+// the filter without fingerprints and the number of fingerprints.
+func parseHeader[T Unsigned](header *[headerSize]byte) (*BinaryFuse[T], uint32) {
+	f := &BinaryFuse[T]{
+		Seed:               binary.LittleEndian.Uint64(header[0:]),
+		SegmentLength:      binary.LittleEndian.Uint32(header[8:]),
+		SegmentLengthMask:  binary.LittleEndian.Uint32(header[12:]),
+		SegmentCount:       binary.LittleEndian.Uint32(header[16:]),
+		SegmentCountLength: binary.LittleEndian.Uint32(header[20:]),
+	}
+	return f, binary.LittleEndian.Uint32(header[24:])
+}
+
+// checkLoad validates the header of a filter with fpLen fingerprints, and
+// takes them out of the budget.
+func checkLoad[T Unsigned](f *BinaryFuse[T], fpLen uint32, budget *loadBudget) error {
+	if err := checkLayout(f.SegmentLength, f.SegmentLengthMask, f.SegmentCount, f.SegmentCountLength, uint64(fpLen)); err != nil {
+		return err
+	}
+	return spend[T](budget, uint64(fpLen), "fingerprints")
+}
+
+// readSlice reads n little endian values.
+func readSlice[T Unsigned | ~uint64](r io.Reader, n uint64) ([]T, error) {
+	return readChunked(n, func(values []T) error {
+		return binary.Read(r, binary.LittleEndian, values)
+	})
+}
+
+// readChunked reads n values by calling read on successive chunks. The slice
+// grows with the data actually read, so that a corrupted length cannot cause
+// a large allocation from a short input, and ends with a capacity of n.
+func readChunked[T any](n uint64, read func([]T) error) ([]T, error) {
+	const chunk = 1 << 16
+	values := make([]T, 0, min(n, chunk))
+	for uint64(len(values)) < n {
+		start := len(values)
+		if len(values) == cap(values) {
+			grown := make([]T, start, min(n, 2*uint64(start)))
+			copy(grown, values)
+			values = grown
+		}
+		end := start + int(min(n-uint64(start), chunk, uint64(cap(values)-start)))
+		values = values[:end]
+		if err := read(values[start:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	return values, nil
+}
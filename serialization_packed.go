@@ -0,0 +1,75 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Save writes the filter to the writer in little endian format.
+func (f *BinaryFusePacked) Save(w io.Writer) error {
+	header := []uint32{f.SegmentLength, f.SegmentLengthMask, f.SegmentCount, f.SegmentCountLength, f.Bits, uint32(len(f.Fingerprints))}
+	if err := binary.Write(w, binary.LittleEndian, f.Seed); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, f.Fingerprints)
+}
+
+// LoadBinaryFusePacked reads the filter from the reader in little endian format.
+func LoadBinaryFusePacked(r io.Reader) (*BinaryFusePacked, error) {
+	return LoadBinaryFusePackedWithOptions(r, LoadOptions{})
+}
+
+// LoadBinaryFusePackedWithOptions is LoadBinaryFusePacked with restrictions
+// on the filters it accepts.
+func LoadBinaryFusePackedWithOptions(r io.Reader, opts LoadOptions) (*BinaryFusePacked, error) {
+	return loadBinaryFusePacked(r, opts.budget())
+}
+
+func loadBinaryFusePacked(r io.Reader, budget *loadBudget) (*BinaryFusePacked, error) {
+	var f BinaryFusePacked
+	if err := binary.Read(r, binary.LittleEndian, &f.Seed); err != nil {
+		return nil, err
+	}
+	var header [6]uint32
+	if err := binary.Read(r, binary.LittleEndian, header[:]); err != nil {
+		return nil, err
+	}
+	f.SegmentLength, f.SegmentLengthMask, f.SegmentCount, f.SegmentCountLength, f.Bits = header[0], header[1], header[2], header[3], header[4]
+	wordsLen := header[5]
+	if err := checkPackedHeader(&f, wordsLen); err != nil {
+		return nil, err
+	}
+	if err := spend[uint64](budget, uint64(wordsLen), "fingerprints"); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.Fingerprints, err = readSlice[uint64](r, uint64(wordsLen)); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// packedHeaderSize is the size of the header written by
+// BinaryFusePacked.Save.
+const packedHeaderSize = 8 + 6*4
+
+// checkPackedHeader validates the parameters of a filter with wordsLen
+// words of fingerprints.
+func checkPackedHeader(f *BinaryFusePacked, wordsLen uint32) error {
+	if f.Bits < 1 || f.Bits > 32 {
+		return errors.New("invalid fingerprint width")
+	}
+	numFingerprints := (uint64(f.SegmentCount) + 2) * uint64(f.SegmentLength)
+	if err := checkLayout(f.SegmentLength, f.SegmentLengthMask, f.SegmentCount, f.SegmentCountLength, numFingerprints); err != nil {
+		return err
+	}
+	if numFingerprints > math.MaxUint32 || wordsLen != packedWords(uint32(numFingerprints), f.Bits) {
+		return errors.New("fingerprint length does not match the filter parameters")
+	}
+	return nil
+}
@@ -3,7 +3,11 @@ package xorfilter
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
 	"reflect"
+	"runtime"
 	"testing"
 )
 
@@ -71,3 +75,174 @@ func TestBinaryFuseSerializationGeneric(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadBinaryFuseHostile(t *testing.T) {
+	// A consistent header declaring 2^32-2^18 32-bit fingerprints (16 GB),
+	// followed by a few bytes.
+	header := make([]byte, 28)
+	binary.LittleEndian.PutUint64(header[0:], 1)
+	binary.LittleEndian.PutUint32(header[8:], 1<<18)
+	binary.LittleEndian.PutUint32(header[12:], 1<<18-1)
+	binary.LittleEndian.PutUint32(header[16:], 1<<14-3)
+	binary.LittleEndian.PutUint32(header[20:], (1<<14-3)<<18)
+	binary.LittleEndian.PutUint32(header[24:], (1<<14-1)<<18)
+	data := append(header, 1, 2, 3, 4, 5, 6)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := LoadBinaryFuse[uint32](bytes.NewReader(data))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for a %d-byte input", allocated, len(data))
+	}
+
+	_, err = LoadBinaryFuseWithOptions[uint32](bytes.NewReader(data), LoadOptions{MaxBytes: 1 << 30})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	// Inconsistent parameters are rejected from the header.
+	binary.LittleEndian.PutUint32(data[24:], 1<<31)
+	if _, err := LoadBinaryFuse[uint32](bytes.NewReader(data)); err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected a layout error, got %v", err)
+	}
+}
+
+func TestLoadBinaryFuseWithOptions(t *testing.T) {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i)
+	}
+	filter, err := NewBinaryFuse[uint16](keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(2 * len(filter.Fingerprints))
+	for _, save := range []func(io.Writer) error{filter.Save, filter.SaveCompressed} {
+		var buf bytes.Buffer
+		if err := save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadBinaryFuseWithOptions[uint16](bytes.NewReader(buf.Bytes()), LoadOptions{MaxBytes: size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(filter, loaded) {
+			t.Error("filters do not match after save/load")
+		}
+		_, err = LoadBinaryFuseWithOptions[uint16](bytes.NewReader(buf.Bytes()), LoadOptions{MaxBytes: size - 1})
+		if !errors.Is(err, ErrTooLarge) {
+			t.Errorf("expected ErrTooLarge, got %v", err)
+		}
+		for _, n := range []int{0, 11, 27, buf.Len() - 1} {
+			if _, err := LoadBinaryFuse[uint16](bytes.NewReader(buf.Bytes()[:n])); err == nil {
+				t.Errorf("no error for a %d-byte prefix", n)
+			}
+		}
+	}
+}
+
+func TestLoadWithOptions(t *testing.T) {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i)
+	}
+	xor, err := Populate(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := NewBinaryFusePacked(keys, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cascade, err := NewCascade(keys[:500], keys[500:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	retained, err := NewRetainedBinaryFuse[uint16](keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluding, err := NewBinaryFuseExcluding[uint8](keys[:500], keys[500:], ExclusionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]int64, len(keys))
+	for i := range items {
+		items[i] = int64(i)
+	}
+	set := NewSet[int64, uint8](IntegerHasher[int64]())
+	if err := set.Build(items); err != nil {
+		t.Fatal(err)
+	}
+	secret := SecretKey{1}
+	keyed, err := NewKeyedBinaryFuse[uint8](secret, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typed bytes.Buffer
+	if err := SaveFilter(&typed, xor); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		save func(io.Writer) error
+		load func(io.Reader, LoadOptions) error
+	}{
+		{"Xor8", xor.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadXor8WithOptions(r, opts)
+			return err
+		}},
+		{"Xor8 compressed", xor.SaveCompressed, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadXor8WithOptions(r, opts)
+			return err
+		}},
+		{"BinaryFusePacked", packed.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadBinaryFusePackedWithOptions(r, opts)
+			return err
+		}},
+		{"Cascade", cascade.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadCascadeWithOptions(r, opts)
+			return err
+		}},
+		{"RetainedBinaryFuse", retained.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadRetainedBinaryFuseWithOptions[uint16](r, opts)
+			return err
+		}},
+		{"BinaryFuseExcluding", excluding.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadBinaryFuseExcludingWithOptions[uint8](r, opts)
+			return err
+		}},
+		{"Set", set.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadSetWithOptions[int64, uint8](r, IntegerHasher[int64](), opts)
+			return err
+		}},
+		{"KeyedBinaryFuse", keyed.Save, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadKeyedBinaryFuseWithOptions[uint8](r, secret, opts)
+			return err
+		}},
+		{"C layout", func(w io.Writer) error { return SaveBinaryFuseC(w, &retained.BinaryFuse, 1000) }, func(r io.Reader, opts LoadOptions) error {
+			_, _, err := LoadBinaryFuseCWithOptions[uint16](r, opts)
+			return err
+		}},
+		{"SaveFilter", func(w io.Writer) error { _, err := w.Write(typed.Bytes()); return err }, func(r io.Reader, opts LoadOptions) error {
+			_, err := LoadFilterWithOptions(r, opts)
+			return err
+		}},
+	} {
+		var buf bytes.Buffer
+		if err := tc.save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := tc.load(bytes.NewReader(buf.Bytes()), LoadOptions{MaxBytes: 1 << 20}); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if err := tc.load(bytes.NewReader(buf.Bytes()), LoadOptions{MaxBytes: 64}); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: expected ErrTooLarge, got %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Save writes the filter to the writer in little endian format: the seed,
+// the block length, the number of fingerprints as a uint32 and the
+// fingerprints.
+func (f *Xor8) Save(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, f.Seed); err != nil {
+		return err
+	}
+	header := []uint32{f.BlockLength, uint32(len(f.Fingerprints))}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err := w.Write(f.Fingerprints)
+	return err
+}
+
+// SaveCompressed writes the filter like Save, with DEFLATE-compressed
+// fingerprints. LoadXor8 reads both formats.
+func (f *Xor8) SaveCompressed(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, f.Seed); err != nil {
+		return err
+	}
+	header := []uint32{compressedMarker, codecDeflate, f.BlockLength, uint32(len(f.Fingerprints))}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return writeDeflated(w, f.Fingerprints)
+}
+
+// LoadXor8 reads the filter, saved by Save or SaveCompressed, from the reader
+// in little endian format.
+func LoadXor8(r io.Reader) (*Xor8, error) {
+	return LoadXor8WithOptions(r, LoadOptions{})
+}
+
+// LoadXor8WithOptions is LoadXor8 with restrictions on the filters it
+// accepts.
+func LoadXor8WithOptions(r io.Reader, opts LoadOptions) (*Xor8, error) {
+	return loadXor8(r, opts.budget())
+}
+
+func loadXor8(r io.Reader, budget *loadBudget) (*Xor8, error) {
+	var f Xor8
+	if err := binary.Read(r, binary.LittleEndian, &f.Seed); err != nil {
+		return nil, err
+	}
+	var blockLength uint32
+	if err := binary.Read(r, binary.LittleEndian, &blockLength); err != nil {
+		return nil, err
+	}
+	compressed := blockLength == compressedMarker
+	if compressed {
+		var header [2]uint32
+		if err := binary.Read(r, binary.LittleEndian, header[:]); err != nil {
+			return nil, err
+		}
+		if header[0] != codecDeflate {
+			return nil, errors.New("unknown compression codec")
+		}
+		blockLength = header[1]
+	}
+	var fpLen uint32
+	if err := binary.Read(r, binary.LittleEndian, &fpLen); err != nil {
+		return nil, err
+	}
+	if err := checkXor8Header(blockLength, fpLen); err != nil {
+		return nil, err
+	}
+	if err := spend[uint8](budget, uint64(fpLen), "fingerprints"); err != nil {
+		return nil, err
+	}
+	f.BlockLength = blockLength
+	var err error
+	if compressed {
+		f.Fingerprints, err = readDeflated[uint8](r, uint64(fpLen))
+	} else {
+		f.Fingerprints, err = readSlice[uint8](r, uint64(fpLen))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// xor8HeaderSize is the size of the header written by Xor8.Save.
+const xor8HeaderSize = 8 + 2*4
+
+// checkXor8Header validates the block length of a filter with fpLen
+// fingerprints.
+func checkXor8Header(blockLength, fpLen uint32) error {
+	if blockLength == 0 || uint64(fpLen) != 3*uint64(blockLength) {
+		return errors.New("fingerprint length does not match the block length")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Hasher maps items to the 64-bit keys of a filter. The ID of the hasher is
+// saved with a Set, so that a Set is only loaded with the hasher it was built
+// with: the hash of an item must never change for a given ID, across
+// versions, processes and architectures.
+type Hasher[K any] struct {
+	ID   string
+	Hash func(K) uint64
+}
+
+// Integer is the constraint of IntegerHasher.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+var (
+	// StringHasher hashes strings with XXH64.
+	StringHasher = Hasher[string]{ID: "xxh64", Hash: xxhash.Sum64String}
+	// BytesHasher hashes byte slices with XXH64. It has the ID of
+	// StringHasher, since both give the same keys for the same bytes.
+	BytesHasher = Hasher[[]byte]{ID: "xxh64", Hash: xxhash.Sum64}
+)
+
+// IntegerHasher returns the hasher using integers as keys, sign-extended to
+// 64 bits: equal values give the same key whatever their type. The filters
+// mix their keys, so integers need no hashing.
+func IntegerHasher[K Integer]() Hasher[K] {
+	return Hasher[K]{ID: "integer", Hash: func(k K) uint64 { return uint64(k) }}
+}
+
+// ArrayHasher returns the hasher of arrays of integers, such as [16]byte
+// UUIDs or [32]byte digests, which hashes their little endian bytes with
+// XXH64. It panics if K is not such an array.
+func ArrayHasher[K any]() Hasher[K] {
+	t := reflect.TypeFor[K]()
+	if t.Kind() != reflect.Array {
+		panic(fmt.Sprintf("xorfilter: ArrayHasher of non-array type %v", t))
+	}
+	switch t.Elem().Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic(fmt.Sprintf("xorfilter: ArrayHasher of array of %v", t.Elem()))
+	}
+	return Hasher[K]{
+		ID: fmt.Sprintf("xxh64-array-%dx%d", t.Len(), t.Elem().Size()),
+		Hash: func(k K) uint64 {
+			return xxhash.Sum64(littleEndianBytes(&k))
+		},
+	}
+}
+
+// Set is a binary fuse filter over items of type K, which are converted to
+// keys by a Hasher.
+type Set[K any, T Unsigned] struct {
+	// Filter is the filter over the hashed items.
+	Filter BinaryFuse[T]
+
+	hasher Hasher[K]
+}
+
+// NewSet returns an empty set using the hasher.
+func NewSet[K any, T Unsigned](hasher Hasher[K]) *Set[K, T] {
+	return &Set[K, T]{hasher: hasher}
+}
+
+// Build replaces the content of the set with the items. The items slice is
+// not modified.
+func (s *Set[K, T]) Build(items []K) error {
+	keys := make([]uint64, len(items))
+	for i, item := range items {
+		keys[i] = s.hasher.Hash(item)
+	}
+	filter, err := NewBinaryFuse[T](keys)
+	if err != nil {
+		return err
+	}
+	s.Filter = *filter
+	return nil
+}
+
+// Contains returns `true` if item is part of the set with a false positive
+// probability.
+func (s *Set[K, T]) Contains(item K) bool {
+	if len(s.Filter.Fingerprints) == 0 {
+		// The set was never built.
+		return false
+	}
+	return s.Filter.Contains(s.hasher.Hash(item))
+}
+
+// ErrHasherMismatch is returned by LoadSet when the set was saved with a
+// different hasher.
+var ErrHasherMismatch = errors.New("set saved with a different hasher")
+
+// setMagic starts the output of Set.Save.
+var setMagic = [4]byte{'X', 'S', 'E', 'T'}
+
+// Save writes a magic number, the length of the hasher ID as a uint16, the
+// hasher ID and the filter, as written by BinaryFuse[T].Save.
+func (s *Set[K, T]) Save(w io.Writer) error {
+	if len(s.hasher.ID) > math.MaxUint16 {
+		return errors.New("hasher ID too long")
+	}
+	header := make([]byte, 0, len(setMagic)+2+len(s.hasher.ID))
+	header = append(header, setMagic[:]...)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(s.hasher.ID)))
+	header = append(header, s.hasher.ID...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	return s.Filter.Save(w)
+}
+
+// LoadSet reads a set written by Set.Save. It fails with ErrHasherMismatch
+// unless the set was saved with a hasher of the same ID.
+func LoadSet[K any, T Unsigned](r io.Reader, hasher Hasher[K]) (*Set[K, T], error) {
+	return LoadSetWithOptions[K, T](r, hasher, LoadOptions{})
+}
+
+// LoadSetWithOptions is LoadSet with restrictions on the sets it accepts.
+func LoadSetWithOptions[K any, T Unsigned](r io.Reader, hasher Hasher[K], opts LoadOptions) (*Set[K, T], error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte(header[:4]) != setMagic {
+		return nil, errors.New("not a set saved by Set.Save")
+	}
+	id := make([]byte, binary.LittleEndian.Uint16(header[4:]))
+	if _, err := io.ReadFull(r, id); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if string(id) != hasher.ID {
+		return nil, fmt.Errorf("%w: %q, expected %q", ErrHasherMismatch, id, hasher.ID)
+	}
+	filter, err := LoadBinaryFuseWithOptions[T](r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Set[K, T]{Filter: *filter, hasher: hasher}, nil
+}
@@ -0,0 +1,78 @@
+package xorfilter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashers(t *testing.T) {
+	// The hashes are part of the saved format and must not change.
+	require.Equal(t, uint64(0xef46db3751d8e999), StringHasher.Hash(""))
+	require.Equal(t, StringHasher.Hash("filter"), BytesHasher.Hash([]byte("filter")))
+	require.Equal(t, StringHasher.ID, BytesHasher.ID)
+
+	require.Equal(t, IntegerHasher[int64]().Hash(-1), IntegerHasher[int8]().Hash(-1))
+	require.Equal(t, uint64(42), IntegerHasher[uint16]().Hash(42))
+
+	words := ArrayHasher[[4]uint16]()
+	require.Equal(t, "xxh64-array-4x2", words.ID)
+	require.Equal(t, xxhash.Sum64([]byte{1, 0, 2, 0, 3, 0, 4, 0}), words.Hash([4]uint16{1, 2, 3, 4}))
+	uuid := ArrayHasher[[16]byte]()
+	require.Equal(t, "xxh64-array-16x1", uuid.ID)
+	require.Equal(t, xxhash.Sum64(make([]byte, 16)), uuid.Hash([16]byte{}))
+
+	require.Panics(t, func() { ArrayHasher[string]() })
+	require.Panics(t, func() { ArrayHasher[[2]string]() })
+}
+
+func TestSet(t *testing.T) {
+	items := make([]string, 10000)
+	for i := range items {
+		items[i] = fmt.Sprintf("user-%d", i)
+	}
+	set := NewSet[string, uint16](StringHasher)
+	require.False(t, set.Contains(items[0]))
+	require.NoError(t, set.Build(items))
+	for _, item := range items {
+		require.True(t, set.Contains(item))
+	}
+	matches := 0
+	for i := range 100000 {
+		if set.Contains(fmt.Sprintf("other-%d", i)) {
+			matches++
+		}
+	}
+	require.Less(t, matches, 10)
+
+	var buf bytes.Buffer
+	require.NoError(t, set.Save(&buf))
+	data := buf.Bytes()
+	loaded, err := LoadSet[string, uint16](bytes.NewReader(data), StringHasher)
+	require.NoError(t, err)
+	require.Equal(t, set.Filter, loaded.Filter)
+	for _, item := range items {
+		require.True(t, loaded.Contains(item))
+	}
+
+	// The same keys can be queried as byte slices.
+	asBytes, err := LoadSet[[]byte, uint16](bytes.NewReader(data), BytesHasher)
+	require.NoError(t, err)
+	require.True(t, asBytes.Contains([]byte(items[7])))
+
+	_, err = LoadSet[[16]byte, uint16](bytes.NewReader(data), ArrayHasher[[16]byte]())
+	require.ErrorIs(t, err, ErrHasherMismatch)
+	_, err = LoadSet[string, uint16](bytes.NewReader(data[:8]), StringHasher)
+	require.Error(t, err)
+	_, err = LoadSet[string, uint16](bytes.NewReader(data[10:]), StringHasher)
+	require.Error(t, err)
+
+	ids := NewSet[int64, uint8](IntegerHasher[int64]())
+	require.NoError(t, ids.Build([]int64{-5, 0, 7, 7, 1 << 40}))
+	for _, id := range []int64{-5, 0, 7, 1 << 40} {
+		require.True(t, ids.Contains(id))
+	}
+}
@@ -0,0 +1,157 @@
+package xorfilter
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A signed filter is made of a header, the filter as written by SaveFilter,
+// and a trailer:
+//
+//	header   "XSIG", the format version as a uint32 and the size of the
+//	         filter as a uint64, little endian
+//	filter   the output of SaveFilter
+//	trailer  the ID of the signing key (see KeyID) and the ed25519
+//	         signature of the header and the filter
+var signedMagic = [4]byte{'X', 'S', 'I', 'G'}
+
+const (
+	signedVersion    = 1
+	signedHeaderSize = 16
+)
+
+var (
+	// ErrNotSigned is returned by LoadVerified for data that is not a
+	// signed filter.
+	ErrNotSigned = errors.New("filter is not signed")
+	// ErrUnknownKey is returned by LoadVerified when the filter was signed
+	// by none of the given keys.
+	ErrUnknownKey = errors.New("filter signed by an unknown key")
+	// ErrInvalidSignature is returned by LoadVerified when the signature does
+	// not match the filter, which was modified after being signed.
+	ErrInvalidSignature = errors.New("invalid filter signature")
+)
+
+// KeyID identifies a public key in signed filters: it is the beginning of
+// the SHA-256 hash of the key.
+func KeyID(pub ed25519.PublicKey) [8]byte {
+	sum := sha256.Sum256(pub)
+	return [8]byte(sum[:8])
+}
+
+// SaveSigned writes the filter signed with the private key. LoadVerified
+// reads it back.
+func (f *BinaryFuse[T]) SaveSigned(w io.Writer, priv ed25519.PrivateKey) error {
+	return saveSigned(w, f, priv)
+}
+
+// SaveSigned writes the filter signed with the private key. LoadVerified
+// reads it back, as a *BinaryFuse[uint8].
+func (f *BinaryFuse8) SaveSigned(w io.Writer, priv ed25519.PrivateKey) error {
+	return saveSigned(w, f, priv)
+}
+
+// SaveSigned writes the filter signed with the private key. LoadVerified
+// reads it back.
+func (f *Xor8) SaveSigned(w io.Writer, priv ed25519.PrivateKey) error {
+	return saveSigned(w, f, priv)
+}
+
+func saveSigned(w io.Writer, f Filter, priv ed25519.PrivateKey) error {
+	if len(priv) != ed25519.PrivateKeySize {
+		return errors.New("invalid ed25519 private key")
+	}
+	var buf bytes.Buffer
+	buf.Write(make([]byte, signedHeaderSize))
+	if err := SaveFilter(&buf, f); err != nil {
+		return err
+	}
+	message := buf.Bytes()
+	copy(message, signedMagic[:])
+	binary.LittleEndian.PutUint32(message[4:], signedVersion)
+	binary.LittleEndian.PutUint64(message[8:], uint64(len(message)-signedHeaderSize))
+	keyID := KeyID(priv.Public().(ed25519.PublicKey))
+	buf.Write(keyID[:])
+	buf.Write(ed25519.Sign(priv, message))
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// LoadVerified reads a filter written by SaveSigned, and returns it if it
+// was signed by one of the public keys. The signature is verified before the
+// filter is decoded: unsigned or modified data is rejected with
+// ErrNotSigned, ErrUnknownKey or ErrInvalidSignature.
+//
+// The filter is read into memory before its signature is verified:
+// opts.MaxBytes bounds its size, as saved by SaveFilter, and should be set
+// for filters from untrusted sources. Larger filters are rejected with
+// ErrTooLarge before they are read.
+//
+// As with LoadFilter, the dynamic type of the result is that of the saved
+// filter, such as *BinaryFuse[uint16] or *Xor8.
+func LoadVerified(r io.Reader, pubKeys []ed25519.PublicKey, opts LoadOptions) (Filter, error) {
+	var header [signedHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, ErrNotSigned
+		}
+		return nil, err
+	}
+	if [4]byte(header[:4]) != signedMagic {
+		return nil, ErrNotSigned
+	}
+	if version := binary.LittleEndian.Uint32(header[4:]); version != signedVersion {
+		return nil, fmt.Errorf("unsupported signature version %d", version)
+	}
+	size := binary.LittleEndian.Uint64(header[8:])
+	if size > 1<<62 {
+		return nil, errors.New("invalid filter size")
+	}
+	if opts.MaxBytes > 0 && size > uint64(opts.MaxBytes) {
+		return nil, fmt.Errorf("%w: %d bytes of signed filter, limit %d", ErrTooLarge, size, opts.MaxBytes)
+	}
+	// The message grows with the data actually read, so that a corrupted
+	// size cannot cause a large allocation.
+	message := bytes.NewBuffer(header[:])
+	if _, err := io.CopyN(message, r, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	var trailer [8 + ed25519.SignatureSize]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	var pub ed25519.PublicKey
+	for _, key := range pubKeys {
+		// ed25519.Verify panics on keys of the wrong size.
+		if len(key) == ed25519.PublicKeySize && KeyID(key) == [8]byte(trailer[:8]) {
+			pub = key
+			break
+		}
+	}
+	if pub == nil {
+		return nil, ErrUnknownKey
+	}
+	if !ed25519.Verify(pub, message.Bytes(), trailer[8:]) {
+		return nil, ErrInvalidSignature
+	}
+	payload := bytes.NewReader(message.Bytes()[signedHeaderSize:])
+	f, err := LoadFilterWithOptions(payload, opts)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Len() != 0 {
+		return nil, errors.New("unexpected data after the filter")
+	}
+	return f, nil
+}
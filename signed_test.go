@@ -0,0 +1,88 @@
+package xorfilter
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedFilter(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherPub, otherPriv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	fuse, err := NewBinaryFuse[uint16](keys)
+	require.NoError(t, err)
+	xor, err := Populate(keys)
+	require.NoError(t, err)
+	fuse8, err := PopulateBinaryFuse8(keys)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		save     func(io.Writer, ed25519.PrivateKey) error
+		expected Filter
+	}{
+		{fuse.SaveSigned, fuse},
+		{xor.SaveSigned, xor},
+		{fuse8.SaveSigned, (*BinaryFuse[uint8])(fuse8)},
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, tc.save(&buf, priv))
+		data := buf.Bytes()
+
+		f, err := LoadVerified(bytes.NewReader(data), []ed25519.PublicKey{otherPub, pub}, LoadOptions{})
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, f)
+
+		// The size limit applies to the filter, without the signature.
+		size := int64(len(data) - signedHeaderSize - 8 - ed25519.SignatureSize)
+		_, err = LoadVerified(bytes.NewReader(data), []ed25519.PublicKey{pub}, LoadOptions{MaxBytes: size})
+		require.NoError(t, err)
+		_, err = LoadVerified(bytes.NewReader(data), []ed25519.PublicKey{pub}, LoadOptions{MaxBytes: size - 1})
+		require.ErrorIs(t, err, ErrTooLarge)
+
+		_, err = LoadVerified(bytes.NewReader(data), []ed25519.PublicKey{otherPub, pub[:10]}, LoadOptions{})
+		require.ErrorIs(t, err, ErrUnknownKey)
+		_, err = LoadVerified(bytes.NewReader(data), nil, LoadOptions{})
+		require.ErrorIs(t, err, ErrUnknownKey)
+
+		// Any modified byte of the header or the filter, or of the
+		// signature, is detected.
+		for _, i := range []int{0, 8, signedHeaderSize + 5, len(data) / 2, len(data) - 1} {
+			tampered := bytes.Clone(data)
+			tampered[i] ^= 1
+			_, err = LoadVerified(bytes.NewReader(tampered), []ed25519.PublicKey{pub}, LoadOptions{})
+			require.Error(t, err, "byte %d", i)
+		}
+		tampered := bytes.Clone(data)
+		tampered[len(data)/2] ^= 1
+		_, err = LoadVerified(bytes.NewReader(tampered), []ed25519.PublicKey{pub}, LoadOptions{})
+		require.ErrorIs(t, err, ErrInvalidSignature)
+
+		// A filter re-signed by another key is rejected.
+		var other bytes.Buffer
+		require.NoError(t, tc.save(&other, otherPriv))
+		_, err = LoadVerified(&other, []ed25519.PublicKey{pub}, LoadOptions{})
+		require.ErrorIs(t, err, ErrUnknownKey)
+
+		for _, n := range []int{10, len(data) / 2, len(data) - 1} {
+			_, err = LoadVerified(bytes.NewReader(data[:n]), []ed25519.PublicKey{pub}, LoadOptions{})
+			require.Error(t, err)
+		}
+	}
+
+	var unsigned bytes.Buffer
+	require.NoError(t, fuse.Save(&unsigned))
+	_, err = LoadVerified(&unsigned, []ed25519.PublicKey{pub}, LoadOptions{})
+	require.ErrorIs(t, err, ErrNotSigned)
+	require.Error(t, fuse.SaveSigned(io.Discard, priv[:5]))
+}
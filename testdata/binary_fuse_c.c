@@ -0,0 +1,61 @@
+// binary_fuse_c.c writes the C-layout fixtures of serialization_c_test.go
+// with the C single-header library (https://github.com/FastFilter/xor_singleheader):
+//
+//	cc -O2 -I path/to/xor_singleheader/include -o binary_fuse_c testdata/binary_fuse_c.c
+//	./binary_fuse_c testdata
+//
+// The filters hold the keys 0, 1, ..., 999.
+#include <stdio.h>
+#include <stdlib.h>
+
+#include "binaryfusefilter.h"
+
+#define SIZE 1000
+
+static int write_file(const char *dir, const char *name, const char *buffer, size_t length) {
+  char path[4096];
+  snprintf(path, sizeof(path), "%s/%s", dir, name);
+  FILE *f = fopen(path, "wb");
+  if (f == NULL || fwrite(buffer, 1, length, f) != length || fclose(f) != 0) {
+    perror(path);
+    return 1;
+  }
+  return 0;
+}
+
+int main(int argc, char **argv) {
+  const char *dir = argc > 1 ? argv[1] : ".";
+  uint64_t keys[SIZE];
+  for (uint64_t i = 0; i < SIZE; i++) {
+    keys[i] = i;
+  }
+
+  binary_fuse8_t filter8;
+  if (!binary_fuse8_allocate(SIZE, &filter8) || !binary_fuse8_populate(keys, SIZE, &filter8)) {
+    fprintf(stderr, "binary_fuse8_populate failed\n");
+    return 1;
+  }
+  size_t length8 = binary_fuse8_serialization_bytes(&filter8);
+  char *buffer8 = malloc(length8);
+  binary_fuse8_serialize(&filter8, buffer8);
+  if (write_file(dir, "binary_fuse8_c.bin", buffer8, length8) != 0) {
+    return 1;
+  }
+  free(buffer8);
+  binary_fuse8_free(&filter8);
+
+  binary_fuse16_t filter16;
+  if (!binary_fuse16_allocate(SIZE, &filter16) || !binary_fuse16_populate(keys, SIZE, &filter16)) {
+    fprintf(stderr, "binary_fuse16_populate failed\n");
+    return 1;
+  }
+  size_t length16 = binary_fuse16_serialization_bytes(&filter16);
+  char *buffer16 = malloc(length16);
+  binary_fuse16_serialize(&filter16, buffer16);
+  if (write_file(dir, "binary_fuse16_c.bin", buffer16, length16) != 0) {
+    return 1;
+  }
+  free(buffer16);
+  binary_fuse16_free(&filter16);
+  return 0;
+}
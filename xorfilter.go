@@ -105,6 +105,7 @@ var MaxIterations = 1024
 
 // Populate fills the filter with provided keys. For best results,
 // the caller should avoid having too many duplicated keys.
+// The keys slice is not modified: duplicates are removed in a copy.
 // The function may return an error if the set is empty.
 func Populate(keys []uint64) (*Xor8, error) {
 	size := len(keys)
@@ -251,7 +252,8 @@ func Populate(keys []uint64) (*Xor8, error) {
 		}
 
 		if iterations == 10 {
-			keys = pruneDuplicates(keys)
+			// Remove duplicates from a copy, leaving the input unchanged.
+			keys = pruneDuplicates(slices.Clone(keys))
 			size = len(keys)
 		}
 